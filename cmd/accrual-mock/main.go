@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/accrualmock"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+)
+
+func main() {
+	addr := flag.String("a", envOrDefault("RUN_ADDRESS", ":8081"), "address to listen on")
+	logLevel := flag.String("l", envOrDefault("LOG_LEVEL", "info"), "log level")
+
+	var cfg accrualmock.Config
+	flag.DurationVar(&cfg.ProcessingDelay, "processing-delay", time.Second, "time an order stays in PROCESSING")
+	flag.DurationVar(&cfg.Latency, "latency", 0, "latency added to every response")
+	flag.IntVar(&cfg.RateLimit, "rate-limit", 0, "order status requests per minute before 429, 0 disables")
+	flag.DurationVar(&cfg.RetryAfter, "retry-after", 0, "Retry-After sent with 429, defaults to the rest of the window")
+	flag.Float64Var(&cfg.ErrorRate, "error-rate", 0, "probability of answering 500 to order status requests")
+	flag.Parse()
+
+	logger, err := logger.New(*logLevel)
+	if err != nil {
+		panic(err)
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           accrualmock.New(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		logger.Infof("app[AccrualMock]", "main[srv.ListenAndServe]", "HTTP server starting...", "address: %s", *addr)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("app[AccrualMock]", "main[srv.ListenAndServe]", "Failed while started http server", err)
+			os.Exit(1)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("app[AccrualMock]", "main[srv.Shutdown]", "Failed while shuttdown http server", err)
+	}
+}
+
+func envOrDefault(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	"github.com/FlyKarlik/gofemart/config"
 	"github.com/FlyKarlik/gofemart/internal/app/gofemart"
 	"github.com/FlyKarlik/gofemart/pkg/logger"
@@ -22,12 +25,19 @@ import (
 // @in header
 // @name Authorization
 func main() {
-	cfg, err := config.New()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file, environment variables override its values")
+	flag.Parse()
+
+	cfg, err := config.New(*configPath)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := validator.Validate(cfg); err != nil {
+	// The migrator settings are not required to run the service.
+	if err := validator.Validate(cfg.AppGofemart); err != nil {
+		panic(err)
+	}
+	if err := validator.Validate(cfg.Infra); err != nil {
 		panic(err)
 	}
 
@@ -36,6 +46,12 @@ func main() {
 		panic(err)
 	}
 
+	effective, err := cfg.Redacted()
+	if err != nil {
+		panic(err)
+	}
+	logger.Info("app[Gofemart]", "main", "Effective config", effective)
+
 	gofemart := gofemart.New(cfg, logger)
 	if err := gofemart.Start(); err != nil {
 		panic(err)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"os"
 
 	"github.com/FlyKarlik/gofemart/config"
@@ -17,17 +18,23 @@ var (
 )
 
 func main() {
-	args := os.Args[1:]
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file, environment variables override its values")
+	flag.Parse()
+
+	args := flag.Args()
 	if len(args) == 0 {
 		panic(ErrNotEnougthArgs)
 	}
 
-	cfg, err := config.New()
+	cfg, err := config.New(*configPath)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := validator.Validate(cfg); err != nil {
+	if err := validator.Validate(cfg.AppMigrator); err != nil {
+		panic(err)
+	}
+	if err := validator.Validate(cfg.Infra.Postgres); err != nil {
 		panic(err)
 	}
 
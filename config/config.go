@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -8,65 +11,162 @@ import (
 )
 
 type Config struct {
-	AppGofemart AppGofemart    `validate:"required"`
-	AppMigrator AppMigrator    `validate:"required"`
-	Infra       Infrastructure `validate:"required"`
+	AppGofemart AppGofemart    `yaml:"gofemart" toml:"gofemart" validate:"required"`
+	AppMigrator AppMigrator    `yaml:"migrator" toml:"migrator" validate:"required"`
+	Infra       Infrastructure `yaml:"infra" toml:"infra" validate:"required"`
 }
 
 type AppGofemart struct {
-	AppMode     string        `env:"APP__GOFEMART__MODE" validate:"required,oneof=dev prod local"`
-	AppName     string        `env:"APP__GOFEMART__NAME" validate:"required,min=3"`
-	LogLevel    string        `env:"APP__GOFEMART__LOG_LEVEL" validate:"required,oneof=debug info warn error"`
-	AppPort     string        `env:"APP__GOFEMART__PORT" validate:"required,numeric,min=4,max=5"`
-	AppHost     string        `env:"APP__GOFEMART__HOST" validate:"required,hostname_rfc1123|ipv4|ipv6"`
-	JWTSecret   string        `env:"APP__GOFEMART__JWT_SECRET" validate:"required"`
-	JWTIssuer   string        `env:"APP__GOFEMART__JWT_ISSUER"    validate:"required,url"`
-	JWTTokenTTL time.Duration `env:"APP__GOFEMART__JWT_TOKEN_TTL" validate:"required,gt=0"`
+	AppMode     string        `env:"APP__GOFEMART__MODE" yaml:"mode" toml:"mode" validate:"required,oneof=dev prod local"`
+	AppName     string        `env:"APP__GOFEMART__NAME" yaml:"name" toml:"name" validate:"required,min=3"`
+	LogLevel    string        `env:"APP__GOFEMART__LOG_LEVEL" yaml:"log_level" toml:"log_level" validate:"required,oneof=debug info warn error"`
+	AppPort     string        `env:"APP__GOFEMART__PORT" yaml:"port" toml:"port" validate:"required,numeric,min=4,max=5"`
+	AppHost     string        `env:"APP__GOFEMART__HOST" yaml:"host" toml:"host" validate:"required,hostname_rfc1123|ipv4|ipv6"`
+	JWTSecret   string        `env:"APP__GOFEMART__JWT_SECRET" yaml:"jwt_secret" toml:"jwt_secret" validate:"required_without=JWTSigningKeyFile"`
+	JWTIssuer   string        `env:"APP__GOFEMART__JWT_ISSUER" yaml:"jwt_issuer" toml:"jwt_issuer"    validate:"required,url"`
+	JWTTokenTTL time.Duration `env:"APP__GOFEMART__JWT_TOKEN_TTL" yaml:"jwt_token_ttl" toml:"jwt_token_ttl" validate:"required,gt=0"`
+
+	JWTRefreshTokenTTL time.Duration `env:"APP__GOFEMART__JWT_REFRESH_TOKEN_TTL" yaml:"jwt_refresh_token_ttl" toml:"jwt_refresh_token_ttl" env-default:"720h" validate:"gt=0"`
+
+	// JWTSigningKeyFile is a PEM RSA or Ed25519 private key. When set, tokens
+	// are signed with it and JWTSecret only verifies tokens issued before.
+	JWTSigningKeyFile       string   `env:"APP__GOFEMART__JWT_SIGNING_KEY_FILE" yaml:"jwt_signing_key_file" toml:"jwt_signing_key_file" validate:"omitempty,file"`
+	JWTVerificationKeyFiles []string `env:"APP__GOFEMART__JWT_VERIFICATION_KEY_FILES" yaml:"jwt_verification_key_files" toml:"jwt_verification_key_files" env-separator:"," validate:"dive,file"`
+
+	AccrualSystemAddress string        `env:"APP__GOFEMART__ACCRUAL_SYSTEM_ADDRESS" yaml:"accrual_system_address" toml:"accrual_system_address" validate:"required,url"`
+	AccrualPollInterval  time.Duration `env:"APP__GOFEMART__ACCRUAL_POLL_INTERVAL" yaml:"accrual_poll_interval" toml:"accrual_poll_interval" env-default:"1s" validate:"gt=0"`
+	AccrualWorkers       int           `env:"APP__GOFEMART__ACCRUAL_WORKERS" yaml:"accrual_workers" toml:"accrual_workers" env-default:"4" validate:"gte=1,lte=100"`
+	AccrualBatchSize     uint64        `env:"APP__GOFEMART__ACCRUAL_BATCH_SIZE" yaml:"accrual_batch_size" toml:"accrual_batch_size" env-default:"100" validate:"gte=1"`
+	// An order still pending after a poll is polled again after
+	// AccrualBackoffBase, doubled on every further poll up to AccrualBackoffMax.
+	AccrualBackoffBase time.Duration `env:"APP__GOFEMART__ACCRUAL_BACKOFF_BASE" yaml:"accrual_backoff_base" toml:"accrual_backoff_base" env-default:"1s" validate:"gt=0"`
+	AccrualBackoffMax  time.Duration `env:"APP__GOFEMART__ACCRUAL_BACKOFF_MAX" yaml:"accrual_backoff_max" toml:"accrual_backoff_max" env-default:"10m" validate:"gtefield=AccrualBackoffBase"`
+
+	// IdempotencyLockTTL bounds how long a key stays reserved by a request
+	// that never finished, IdempotencyKeyTTL how long its response is kept.
+	IdempotencyLockTTL time.Duration `env:"APP__GOFEMART__IDEMPOTENCY_LOCK_TTL" yaml:"idempotency_lock_ttl" toml:"idempotency_lock_ttl" env-default:"30s" validate:"gt=0"`
+	IdempotencyKeyTTL  time.Duration `env:"APP__GOFEMART__IDEMPOTENCY_KEY_TTL" yaml:"idempotency_key_ttl" toml:"idempotency_key_ttl" env-default:"24h" validate:"gtefield=IdempotencyLockTTL"`
+
+	// TrustedProxies lists the IPs and CIDRs allowed to set the client IP in
+	// X-Forwarded-For and X-Real-IP. Empty trusts none and uses the peer
+	// address, which is what limits login attempts per IP.
+	TrustedProxies []string `env:"APP__GOFEMART__TRUSTED_PROXIES" yaml:"trusted_proxies" toml:"trusted_proxies" env-separator:"," validate:"dive,ip|cidr"`
+
+	LoginMaxAttempts      int64         `env:"APP__GOFEMART__LOGIN_MAX_ATTEMPTS" yaml:"login_max_attempts" toml:"login_max_attempts" env-default:"5" validate:"gte=1"`
+	LoginMaxAttemptsPerIP int64         `env:"APP__GOFEMART__LOGIN_MAX_ATTEMPTS_PER_IP" yaml:"login_max_attempts_per_ip" toml:"login_max_attempts_per_ip" env-default:"20" validate:"gte=1"`
+	LoginAttemptWindow    time.Duration `env:"APP__GOFEMART__LOGIN_ATTEMPT_WINDOW" yaml:"login_attempt_window" toml:"login_attempt_window" env-default:"15m" validate:"gt=0"`
+	LoginLockoutBase      time.Duration `env:"APP__GOFEMART__LOGIN_LOCKOUT_BASE" yaml:"login_lockout_base" toml:"login_lockout_base" env-default:"1m" validate:"gt=0"`
+	LoginLockoutMax       time.Duration `env:"APP__GOFEMART__LOGIN_LOCKOUT_MAX" yaml:"login_lockout_max" toml:"login_lockout_max" env-default:"1h" validate:"gtefield=LoginLockoutBase"`
+
+	// PasswordMaxLength counts bytes, bcrypt rejects passwords past 72.
+	PasswordMinLength  int `env:"APP__GOFEMART__PASSWORD_MIN_LENGTH" yaml:"password_min_length" toml:"password_min_length" env-default:"8" validate:"gte=1"`
+	PasswordMaxLength  int `env:"APP__GOFEMART__PASSWORD_MAX_LENGTH" yaml:"password_max_length" toml:"password_max_length" env-default:"72" validate:"lte=72,gtefield=PasswordMinLength"`
+	PasswordMinClasses int `env:"APP__GOFEMART__PASSWORD_MIN_CLASSES" yaml:"password_min_classes" toml:"password_min_classes" env-default:"2" validate:"gte=0,lte=4"`
+	// PasswordHashAlgorithm hashes new passwords. Stored hashes of the other
+	// algorithm, or of other parameters, are replaced at the next successful
+	// login. PasswordArgon2Memory is in KiB.
+	PasswordHashAlgorithm string `env:"APP__GOFEMART__PASSWORD_HASH_ALGORITHM" yaml:"password_hash_algorithm" toml:"password_hash_algorithm" env-default:"argon2id" validate:"oneof=argon2id bcrypt"`
+	PasswordArgon2Memory  uint32 `env:"APP__GOFEMART__PASSWORD_ARGON2_MEMORY" yaml:"password_argon2_memory" toml:"password_argon2_memory" env-default:"19456" validate:"gte=8192"`
+	PasswordArgon2Time    uint32 `env:"APP__GOFEMART__PASSWORD_ARGON2_TIME" yaml:"password_argon2_time" toml:"password_argon2_time" env-default:"2" validate:"gte=1"`
+	PasswordArgon2Threads uint8  `env:"APP__GOFEMART__PASSWORD_ARGON2_THREADS" yaml:"password_argon2_threads" toml:"password_argon2_threads" env-default:"1" validate:"gte=1"`
+	PasswordBcryptCost    int    `env:"APP__GOFEMART__PASSWORD_BCRYPT_COST" yaml:"password_bcrypt_cost" toml:"password_bcrypt_cost" env-default:"10" validate:"gte=4,lte=31"`
+
+	// UserCacheTTL is how long a user lives in Redis. Requests are served from
+	// an in-process L1 for UserCacheL1TTL, then from a stale L1 entry for
+	// UserCacheStaleTTL more while it is refreshed in the background.
+	UserCacheTTL      time.Duration `env:"APP__GOFEMART__USER_CACHE_TTL" yaml:"user_cache_ttl" toml:"user_cache_ttl" env-default:"10m" validate:"gt=0"`
+	UserCacheL1Size   int           `env:"APP__GOFEMART__USER_CACHE_L1_SIZE" yaml:"user_cache_l1_size" toml:"user_cache_l1_size" env-default:"10000" validate:"gte=1"`
+	UserCacheL1TTL    time.Duration `env:"APP__GOFEMART__USER_CACHE_L1_TTL" yaml:"user_cache_l1_ttl" toml:"user_cache_l1_ttl" env-default:"30s" validate:"gt=0"`
+	UserCacheStaleTTL time.Duration `env:"APP__GOFEMART__USER_CACHE_STALE_TTL" yaml:"user_cache_stale_ttl" toml:"user_cache_stale_ttl" env-default:"1m" validate:"gte=0"`
+
+	// BalanceCacheTTL bounds how long a balance is served from Redis. It is
+	// invalidated on every change, the TTL only matters when that fails.
+	BalanceCacheTTL time.Duration `env:"APP__GOFEMART__BALANCE_CACHE_TTL" yaml:"balance_cache_ttl" toml:"balance_cache_ttl" env-default:"1m" validate:"gt=0"`
+
+	// HealthCriticalChecks lists the dependencies whose failure makes the
+	// readiness probe fail. Other dependencies only degrade it.
+	HealthCriticalChecks       []string      `env:"APP__GOFEMART__HEALTH_CRITICAL_CHECKS" yaml:"health_critical_checks" toml:"health_critical_checks" env-separator:"," env-default:"postgres,redis" validate:"dive,oneof=postgres redis accrual_backlog"`
+	HealthCheckTimeout         time.Duration `env:"APP__GOFEMART__HEALTH_CHECK_TIMEOUT" yaml:"health_check_timeout" toml:"health_check_timeout" env-default:"2s" validate:"gt=0"`
+	HealthAccrualBacklogMaxAge time.Duration `env:"APP__GOFEMART__HEALTH_ACCRUAL_BACKLOG_MAX_AGE" yaml:"health_accrual_backlog_max_age" toml:"health_accrual_backlog_max_age" env-default:"10m" validate:"gt=0"`
+	ShutdownDrainDelay         time.Duration `env:"APP__GOFEMART__SHUTDOWN_DRAIN_DELAY" yaml:"shutdown_drain_delay" toml:"shutdown_drain_delay" env-default:"0s" validate:"gte=0"`
+
+	// ShutdownTimeout bounds the graceful shutdown after the drain delay.
+	// Connections still open when it passes are closed.
+	ShutdownTimeout time.Duration `env:"APP__GOFEMART__SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout" toml:"shutdown_timeout" env-default:"15s" validate:"gt=0"`
 }
 
 type AppMigrator struct {
-	LogLevel       string `env:"APP__MIGRATOR__LOG_LEVEL" validate:"required,oneof=debug info warn error"`
-	AppMode        string `env:"APP__MIGRATOR__MODE" validate:"required,oneof=dev prod local"`
-	ServiceName    string `env:"APP__MIGRATOR__NAME" validate:"required,min=3"`
-	MigrationsPath string `env:"APP__MIGRATOR__MIGRATIONS_PATH" validate:"required"`
+	LogLevel       string `env:"APP__MIGRATOR__LOG_LEVEL" yaml:"log_level" toml:"log_level" validate:"required,oneof=debug info warn error"`
+	AppMode        string `env:"APP__MIGRATOR__MODE" yaml:"mode" toml:"mode" validate:"required,oneof=dev prod local"`
+	ServiceName    string `env:"APP__MIGRATOR__NAME" yaml:"name" toml:"name" validate:"required,min=3"`
+	MigrationsPath string `env:"APP__MIGRATOR__MIGRATIONS_PATH" yaml:"migrations_path" toml:"migrations_path" validate:"required"`
 }
 
 type Infrastructure struct {
-	Postgres PostgreSQL `validate:"required"`
-	Redis    Redis      `validate:"required"`
-	Jaeger   Jaeger     `validate:"required"`
+	Postgres PostgreSQL `yaml:"postgres" toml:"postgres" validate:"required"`
+	Redis    Redis      `yaml:"redis" toml:"redis" validate:"required"`
+	Jaeger   Jaeger     `yaml:"jaeger" toml:"jaeger" validate:"required"`
 }
 
 type PostgreSQL struct {
-	Host     string `env:"INFRA__POSTGRES__HOST" validate:"required,hostname|ip"`
-	Port     string `env:"INFRA__POSTGRES__PORT" validate:"required,numeric"`
-	User     string `env:"INFRA__POSTGRES__USER" validate:"required"`
-	Password string `env:"INFRA__POSTGRES__PASSWORD" validate:"required"`
-	Database string `env:"INFRA__POSTGRES__DATABASE" validate:"required,alphaunicode"`
-	ConnStr  string `env:"INFRA__POSTGRES__CONN_STR" validate:"required,url"`
+	Host     string `env:"INFRA__POSTGRES__HOST" yaml:"host" toml:"host" validate:"required,hostname|ip"`
+	Port     string `env:"INFRA__POSTGRES__PORT" yaml:"port" toml:"port" validate:"required,numeric"`
+	User     string `env:"INFRA__POSTGRES__USER" yaml:"user" toml:"user" validate:"required"`
+	Password string `env:"INFRA__POSTGRES__PASSWORD" yaml:"password" toml:"password" validate:"required"`
+	Database string `env:"INFRA__POSTGRES__DATABASE" yaml:"database" toml:"database" validate:"required,alphaunicode"`
+	ConnStr  string `env:"INFRA__POSTGRES__CONN_STR" yaml:"conn_str" toml:"conn_str" validate:"required,url"`
+
+	TxIsolationLevel string `env:"INFRA__POSTGRES__TX_ISOLATION_LEVEL" yaml:"tx_isolation_level" toml:"tx_isolation_level" env-default:"read_committed" validate:"oneof=read_committed repeatable_read serializable"`
+	TxRetryAttempts  int    `env:"INFRA__POSTGRES__TX_RETRY_ATTEMPTS" yaml:"tx_retry_attempts" toml:"tx_retry_attempts" env-default:"3" validate:"gte=1,lte=10"`
 }
 
 type Redis struct {
-	Host         string `env:"INFRA__REDIS__HOST" validate:"required,hostname|ip"`
-	Port         string `env:"INFRA__REDIS__PORT" validate:"required,numeric"`
-	Password     string `env:"INFRA__REDIS__PASSWORD"`
-	Database     int    `env:"INFRA__REDIS__DATABASE" validate:"gte=0,lte=15"`
-	PoolSize     int    `env:"INFRA__REDIS__POOL_SIZE" validate:"gte=1,lte=100"`
-	MinIdleConns int    `env:"INFRA__REDIS__MIN_IDLE_CONNS" validate:"gte=0"`
-	PoolTimeout  int    `env:"INFRA__REDIS__POOL_TIMEOUT" validate:"gte=1"`
+	Host         string `env:"INFRA__REDIS__HOST" yaml:"host" toml:"host" validate:"required,hostname|ip"`
+	Port         string `env:"INFRA__REDIS__PORT" yaml:"port" toml:"port" validate:"required,numeric"`
+	Password     string `env:"INFRA__REDIS__PASSWORD" yaml:"password" toml:"password"`
+	Database     int    `env:"INFRA__REDIS__DATABASE" yaml:"database" toml:"database" validate:"gte=0,lte=15"`
+	PoolSize     int    `env:"INFRA__REDIS__POOL_SIZE" yaml:"pool_size" toml:"pool_size" validate:"gte=1,lte=100"`
+	MinIdleConns int    `env:"INFRA__REDIS__MIN_IDLE_CONNS" yaml:"min_idle_conns" toml:"min_idle_conns" validate:"gte=0"`
+	PoolTimeout  int    `env:"INFRA__REDIS__POOL_TIMEOUT" yaml:"pool_timeout" toml:"pool_timeout" validate:"gte=1"`
+
+	// BreakerThreshold consecutive failures stop calls to Redis from caches
+	// that can do without it for BreakerCooldown.
+	BreakerThreshold int           `env:"INFRA__REDIS__BREAKER_THRESHOLD" yaml:"breaker_threshold" toml:"breaker_threshold" env-default:"5" validate:"gte=1"`
+	BreakerCooldown  time.Duration `env:"INFRA__REDIS__BREAKER_COOLDOWN" yaml:"breaker_cooldown" toml:"breaker_cooldown" env-default:"10s" validate:"gt=0"`
+
+	// CacheNamespace prefixes the keys of typed caches, CacheCodec encodes
+	// their values.
+	CacheNamespace string `env:"INFRA__REDIS__CACHE_NAMESPACE" yaml:"cache_namespace" toml:"cache_namespace" env-default:"gofemart" validate:"required"`
+	CacheCodec     string `env:"INFRA__REDIS__CACHE_CODEC" yaml:"cache_codec" toml:"cache_codec" env-default:"json" validate:"oneof=json msgpack"`
 }
 
 type Jaeger struct {
-	ServiceName string `env:"JAEGER_SERVICE_NAME" validate:"required"`
-	Host        string `env:"JAEGER_AGENT_HOST" validate:"required,hostname|ip"`
-	Port        string `env:"JAEGER_AGENT_PORT" validate:"required,numeric"`
-	LogSpans    bool   `env:"JAEGER_LOG_SPANS"`
-	Enabled     bool   `env:"JAEGER_ENABLED"`
+	ServiceName string `env:"JAEGER_SERVICE_NAME" yaml:"service_name" toml:"service_name" validate:"required"`
+	Host        string `env:"JAEGER_AGENT_HOST" yaml:"host" toml:"host" validate:"required,hostname|ip"`
+	Port        string `env:"JAEGER_AGENT_PORT" yaml:"port" toml:"port" validate:"required,numeric"`
+	LogSpans    bool   `env:"JAEGER_LOG_SPANS" yaml:"log_spans" toml:"log_spans"`
+	Enabled     bool   `env:"JAEGER_ENABLED" yaml:"enabled" toml:"enabled"`
 }
 
-func New() (*Config, error) {
+// New reads the configuration from the environment. When path is not empty
+// the YAML or TOML file at path is read first and environment variables
+// override the values it sets.
+func New(path string) (*Config, error) {
 	cfg := &Config{}
-	if err := cleanenv.ReadEnv(cfg); err != nil {
+	if path == "" {
+		if err := cleanenv.ReadEnv(cfg); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".toml":
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", ext)
+	}
+
+	if err := cleanenv.ReadConfig(path, cfg); err != nil {
 		return nil, err
 	}
 	return cfg, nil
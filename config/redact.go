@@ -0,0 +1,55 @@
+package config
+
+import (
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "xxxxx"
+
+// Redacted renders the config in the layout of a config file with secrets
+// masked and passwords stripped from connection strings, for logging.
+func (c Config) Redacted() (map[string]interface{}, error) {
+	if c.AppGofemart.JWTSecret != "" {
+		c.AppGofemart.JWTSecret = redacted
+	}
+	if c.Infra.Postgres.Password != "" {
+		c.Infra.Postgres.Password = redacted
+	}
+	if c.Infra.Redis.Password != "" {
+		c.Infra.Redis.Password = redacted
+	}
+	c.Infra.Postgres.ConnStr = redactURL(c.Infra.Postgres.ConnStr)
+
+	// Going through YAML keeps the file keys and prints durations as
+	// "15m0s" instead of nanoseconds.
+	raw, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var out map[string]interface{}
+	if err := yaml.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func redactURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+
+	q := u.Query()
+	if q.Has("password") {
+		q.Set("password", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
@@ -47,13 +47,53 @@ const docTemplate = `{
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/balance/ledger": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves every posting that moved the user's points, oldest first, with running totals",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Balance"
+                ],
+                "summary": "Get user balance ledger",
+                "responses": {
+                    "200": {
+                        "description": "Successful response with ledger entries",
+                        "schema": {
+                            "$ref": "#/definitions/response.BaseResponseLedger"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
                         }
                     }
                 }
@@ -86,6 +126,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/response.WitdrawalUserBalanceInput"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -98,31 +144,37 @@ const docTemplate = `{
                     "400": {
                         "description": "Invalid request format",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "402": {
                         "description": "Insufficient funds",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Request with the same idempotency key is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "422": {
-                        "description": "Invalid order number format",
+                        "description": "Invalid order number format or idempotency key reused with another request",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     }
                 }
@@ -130,7 +182,7 @@ const docTemplate = `{
         },
         "/api/user/login": {
             "post": {
-                "description": "Verifies user credentials and returns JWT token",
+                "description": "Verifies user credentials and returns JWT access token with a refresh token",
                 "consumes": [
                     "application/json"
                 ],
@@ -162,20 +214,88 @@ const docTemplate = `{
                     "400": {
                         "description": "Invalid request format",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "401": {
                         "description": "Authentication failed",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts, retry after the Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until the next attempt is allowed"
+                            }
                         }
                     },
                     "500": {
                         "description": "Server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/logout": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revokes the access token of the request. When a refresh token is passed, every token of its session is revoked too",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Logout user",
+                "parameters": [
+                    {
+                        "description": "Refresh token to revoke",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.LogoutInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Logged out",
                         "schema": {
                             "$ref": "#/definitions/response.BaseResponseAny"
                         }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
                     }
                 }
             }
@@ -187,7 +307,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Retrieves all orders uploaded by the authenticated user",
+                "description": "Retrieves orders uploaded by the authenticated user, one page at a time",
                 "consumes": [
                     "application/json"
                 ],
@@ -198,6 +318,60 @@ const docTemplate = `{
                     "Orders"
                 ],
                 "summary": "Get user's orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Page size, 1 to 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Lower time bound, inclusive, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upper time bound, exclusive, RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "asc",
+                        "description": "Sort direction by time",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "enum": [
+                                "NEW",
+                                "PROCESSING",
+                                "INVALID",
+                                "PROCESSED"
+                            ],
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Order statuses to include",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Successful response with orders",
@@ -208,16 +382,22 @@ const docTemplate = `{
                     "204": {
                         "description": "No orders found for user"
                     },
+                    "400": {
+                        "description": "Invalid listing parameters",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     }
                 }
@@ -228,8 +408,9 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Accepts a plain text order number and processes it",
+                "description": "Accepts an order number as a text/plain body, or as JSON {\"number\": \"...\"}, and queues it for accrual",
                 "consumes": [
+                    "text/plain",
                     "application/json"
                 ],
                 "produces": [
@@ -246,8 +427,14 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.UserOrderInput"
+                            "type": "string"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key to safely retry the request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -258,37 +445,164 @@ const docTemplate = `{
                         }
                     },
                     "202": {
-                        "description": "New order accepted for processing"
+                        "description": "New order accepted for processing",
+                        "schema": {
+                            "$ref": "#/definitions/response.BaseResponseAny"
+                        }
                     },
                     "400": {
                         "description": "Invalid request format",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "409": {
                         "description": "Order already uploaded by another user",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "422": {
                         "description": "Invalid order number format",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/orders/events": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Keeps the connection open and sends an \"order\" event with the order state whenever one of the user's orders changes status or accrual. A comment line is sent every 15 seconds to keep proxies from closing the stream",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Orders"
+                ],
+                "summary": "Stream order events",
+                "responses": {
+                    "200": {
+                        "description": "Stream of order events",
+                        "schema": {
+                            "$ref": "#/definitions/model.OrderEvent"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/password": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replaces the password after checking the current one and revokes every access and refresh token of the user, so all sessions have to log in again",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Change password",
+                "parameters": [
+                    {
+                        "description": "Current and new password",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ChangePasswordInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Password changed",
                         "schema": {
                             "$ref": "#/definitions/response.BaseResponseAny"
                         }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Current password is wrong",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "New password breaks the password policy, see errors",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts, retry after the Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "Seconds until the next attempt is allowed"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
                     }
                 }
             }
@@ -327,19 +641,77 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad request - invalid input params",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "409": {
                         "description": "Conflict - user login in use",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Password breaks the password policy, see errors",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal system error",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/user/token/refresh": {
+            "post": {
+                "description": "Rotates the refresh token and issues a new access token. A refresh token can be used only once, reusing it revokes all tokens of the session",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Refresh tokens",
+                "parameters": [
+                    {
+                        "description": "Refresh token",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.RefreshTokenInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "New token pair",
+                        "schema": {
+                            "$ref": "#/definitions/response.BaseResponseLogin"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Refresh token is invalid, expired or revoked",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
                         }
                     }
                 }
@@ -352,7 +724,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Retrieves a list of all user's balance withdrawals",
+                "description": "Retrieves user's balance withdrawals, one page at a time",
                 "consumes": [
                     "application/json"
                 ],
@@ -363,6 +735,44 @@ const docTemplate = `{
                     "Balance"
                 ],
                 "summary": "Get user withdrawals",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Page size, 1 to 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Lower time bound, inclusive, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upper time bound, exclusive, RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "asc",
+                        "description": "Sort direction by time",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Successful response with withdrawal history",
@@ -373,16 +783,22 @@ const docTemplate = `{
                     "204": {
                         "description": "No withdrawals found"
                     },
+                    "400": {
+                        "description": "Invalid listing parameters",
+                        "schema": {
+                            "$ref": "#/definitions/response.Problem"
+                        }
+                    },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/response.BaseResponseAny"
+                            "$ref": "#/definitions/response.Problem"
                         }
                     }
                 }
@@ -390,6 +806,60 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "errs.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ChangePasswordInput": {
+            "type": "object",
+            "required": [
+                "new_password",
+                "old_password"
+            ],
+            "properties": {
+                "new_password": {
+                    "type": "string"
+                },
+                "old_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.LogoutInput": {
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.OrderEvent": {
+            "type": "object",
+            "properties": {
+                "accrual": {
+                    "type": "number"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.OrderStatusEnum"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
         "model.OrderStatusEnum": {
             "type": "string",
             "enum": [
@@ -405,6 +875,17 @@ const docTemplate = `{
                 "OrderStatusEnumProcessed"
             ]
         },
+        "model.RefreshTokenInput": {
+            "type": "object",
+            "required": [
+                "refresh_token"
+            ],
+            "properties": {
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
         "model.UserInput": {
             "type": "object",
             "required": [
@@ -424,7 +905,7 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "accural": {
-                    "type": "integer"
+                    "type": "number"
                 },
                 "id": {
                     "type": "string"
@@ -443,49 +924,43 @@ const docTemplate = `{
                 }
             }
         },
-        "model.UserOrderInput": {
+        "response.BaseResponseAny": {
             "type": "object",
-            "required": [
-                "number"
-            ],
             "properties": {
-                "number": {
-                    "type": "string"
+                "code": {
+                    "type": "integer"
                 },
+                "data": {},
                 "status": {
-                    "$ref": "#/definitions/model.OrderStatusEnum"
-                },
-                "userID": {
-                    "type": "string"
+                    "type": "boolean"
                 }
             }
         },
-        "response.BaseResponseAny": {
+        "response.BaseResponseBalance": {
             "type": "object",
             "properties": {
                 "code": {
                     "type": "integer"
                 },
-                "data": {},
-                "error": {
-                    "type": "string"
+                "data": {
+                    "$ref": "#/definitions/response.UserBalanceBalance"
                 },
                 "status": {
                     "type": "boolean"
                 }
             }
         },
-        "response.BaseResponseBalance": {
+        "response.BaseResponseLedger": {
             "type": "object",
             "properties": {
                 "code": {
                     "type": "integer"
                 },
                 "data": {
-                    "$ref": "#/definitions/response.UserBalanceBalance"
-                },
-                "error": {
-                    "type": "string"
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.LedgerEntryData"
+                    }
                 },
                 "status": {
                     "type": "boolean"
@@ -501,14 +976,14 @@ const docTemplate = `{
                 "data": {
                     "type": "object",
                     "properties": {
+                        "refresh_token": {
+                            "type": "string"
+                        },
                         "token": {
                             "type": "string"
                         }
                     }
                 },
-                "error": {
-                    "type": "string"
-                },
                 "status": {
                     "type": "boolean"
                 }
@@ -526,7 +1001,7 @@ const docTemplate = `{
                         "$ref": "#/definitions/model.UserOrder"
                     }
                 },
-                "error": {
+                "next_cursor": {
                     "type": "string"
                 },
                 "status": {
@@ -546,7 +1021,7 @@ const docTemplate = `{
                         "$ref": "#/definitions/response.UserWithdrawalData"
                     }
                 },
-                "error": {
+                "next_cursor": {
                     "type": "string"
                 },
                 "status": {
@@ -554,6 +1029,80 @@ const docTemplate = `{
                 }
             }
         },
+        "response.LedgerEntryData": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "balance_after": {
+                    "type": "number"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "credit_account": {
+                    "type": "string"
+                },
+                "debit_account": {
+                    "type": "string"
+                },
+                "entry_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "order_number": {
+                    "type": "string"
+                },
+                "reversal_of": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "withdrawn_after": {
+                    "type": "number"
+                }
+            }
+        },
+        "response.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Errors lists the rejected input fields of a VALIDATION_FAILED problem.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/errs.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "trace_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
         "response.UserBalanceBalance": {
             "type": "object",
             "properties": {
@@ -0,0 +1,295 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/pkg/accrual"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+)
+
+type RewardType string
+
+const (
+	RewardTypePercent RewardType = "%"
+	RewardTypePoints  RewardType = "pt"
+)
+
+// Config controls the behaviour of the stand-in accrual system. Zero values
+// disable the corresponding fault injection.
+type Config struct {
+	// ProcessingDelay is the time an order stays in PROCESSING before it is
+	// reported as PROCESSED or INVALID.
+	ProcessingDelay time.Duration
+	// Latency is added to every response.
+	Latency time.Duration
+	// RateLimit is the number of GET /api/orders/{number} requests served per
+	// minute before answering 429 Too Many Requests.
+	RateLimit int
+	// RetryAfter is the value sent in the Retry-After header with 429.
+	RetryAfter time.Duration
+	// ErrorRate is the probability in [0, 1] of answering 500 to an order
+	// status request.
+	ErrorRate float64
+}
+
+type Good struct {
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+}
+
+type RewardRule struct {
+	Match      string     `json:"match"`
+	Reward     float64    `json:"reward"`
+	RewardType RewardType `json:"reward_type"`
+}
+
+type OrderInput struct {
+	Order string `json:"order"`
+	Goods []Good `json:"goods"`
+}
+
+type order struct {
+	number       string
+	accrual      float64
+	valid        bool
+	registeredAt time.Time
+	status       accrual.OrderStatus
+}
+
+// Server implements the accrual system API contract in memory.
+type Server struct {
+	cfg Config
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	rules  map[string]RewardRule
+	orders map[string]*order
+
+	windowStart time.Time
+	windowCount int
+}
+
+func New(cfg Config) *Server {
+	s := &Server{
+		cfg:    cfg,
+		mux:    http.NewServeMux(),
+		rules:  make(map[string]RewardRule),
+		orders: make(map[string]*order),
+	}
+
+	s.mux.HandleFunc("POST /api/goods", s.registerRewardRule)
+	s.mux.HandleFunc("POST /api/orders", s.registerOrder)
+	s.mux.HandleFunc("GET /api/orders/{number}", s.getOrder)
+
+	return s
+}
+
+// NewHTTPTestServer starts the stand-in on a local loopback port. Callers
+// must Close the returned server.
+func NewHTTPTestServer(cfg Config) (*httptest.Server, *Server) {
+	s := New(cfg)
+	return httptest.NewServer(s), s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Latency > 0 {
+		time.Sleep(s.cfg.Latency)
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetOrder seeds the final state of an order, bypassing reward rules and the
+// processing delay.
+func (s *Server) SetOrder(number string, status accrual.OrderStatus, accrualValue float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders[number] = &order{
+		number:       number,
+		accrual:      accrualValue,
+		valid:        status != accrual.OrderStatusInvalid,
+		registeredAt: time.Now(),
+		status:       status,
+	}
+}
+
+func (s *Server) registerRewardRule(w http.ResponseWriter, r *http.Request) {
+	var rule RewardRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil || rule.Match == "" || rule.Reward <= 0 {
+		http.Error(w, "invalid reward rule", http.StatusBadRequest)
+		return
+	}
+
+	if rule.RewardType != RewardTypePercent && rule.RewardType != RewardTypePoints {
+		http.Error(w, "invalid reward type", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rules[rule.Match]; ok {
+		http.Error(w, "reward rule already registered", http.StatusConflict)
+		return
+	}
+	s.rules[rule.Match] = rule
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) registerOrder(w http.ResponseWriter, r *http.Request) {
+	var input OrderInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Order == "" {
+		http.Error(w, "invalid order", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[input.Order]; ok {
+		http.Error(w, "order already registered", http.StatusConflict)
+		return
+	}
+
+	s.orders[input.Order] = &order{
+		number:       input.Order,
+		accrual:      s.calculateAccrual(input.Goods),
+		valid:        isValidLuhn(input.Order),
+		registeredAt: time.Now(),
+		status:       accrual.OrderStatusRegistered,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	if retryAfter, limited := s.takeRateLimit(); limited {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "No more than "+strconv.Itoa(s.cfg.RateLimit)+" requests per minute allowed", http.StatusTooManyRequests)
+		return
+	}
+
+	if s.cfg.ErrorRate > 0 && rand.Float64() < s.cfg.ErrorRate {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	o, ok := s.orders[r.PathValue("number")]
+	if !ok {
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp := s.advance(o)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// advance moves an order through REGISTERED -> PROCESSING -> PROCESSED/INVALID
+// based on the time elapsed since registration. Must be called with mu held.
+func (s *Server) advance(o *order) accrual.OrderAccrual {
+	switch o.status {
+	case accrual.OrderStatusRegistered:
+		o.status = accrual.OrderStatusProcessing
+	case accrual.OrderStatusProcessing:
+		if time.Since(o.registeredAt) >= s.cfg.ProcessingDelay {
+			if o.valid {
+				o.status = accrual.OrderStatusProcessed
+			} else {
+				o.status = accrual.OrderStatusInvalid
+			}
+		}
+	}
+
+	resp := accrual.OrderAccrual{
+		Order:  o.number,
+		Status: o.status,
+	}
+	if o.status == accrual.OrderStatusProcessed {
+		value := money.FromMinor(int64(math.Round(o.accrual * money.Scale)))
+		resp.Accrual = &value
+	}
+	return resp
+}
+
+// calculateAccrual must be called with mu held.
+func (s *Server) calculateAccrual(goods []Good) float64 {
+	var total float64
+	for _, good := range goods {
+		for match, rule := range s.rules {
+			if !strings.Contains(good.Description, match) {
+				continue
+			}
+			switch rule.RewardType {
+			case RewardTypePercent:
+				total += good.Price * rule.Reward / 100
+			case RewardTypePoints:
+				total += rule.Reward
+			}
+			break
+		}
+	}
+	return total
+}
+
+func (s *Server) takeRateLimit() (time.Duration, bool) {
+	if s.cfg.RateLimit <= 0 {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart = now
+		s.windowCount = 0
+	}
+
+	if s.windowCount >= s.cfg.RateLimit {
+		retryAfter := s.cfg.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = time.Minute - now.Sub(s.windowStart)
+		}
+		return retryAfter, true
+	}
+
+	s.windowCount++
+	return 0, false
+}
+
+func isValidLuhn(number string) bool {
+	if number == "" {
+		return false
+	}
+
+	var sum int
+	var alt bool
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+		n := int(number[i] - '0')
+		if alt {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		alt = !alt
+	}
+	return sum%10 == 0
+}
@@ -6,17 +6,24 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/FlyKarlik/gofemart/config"
 	"github.com/FlyKarlik/gofemart/internal/delivery/http/handler"
 	"github.com/FlyKarlik/gofemart/internal/delivery/http/middleware"
 	"github.com/FlyKarlik/gofemart/internal/delivery/http/router"
 	"github.com/FlyKarlik/gofemart/internal/delivery/http/server"
+	"github.com/FlyKarlik/gofemart/internal/health"
+	"github.com/FlyKarlik/gofemart/internal/metrics"
 	"github.com/FlyKarlik/gofemart/internal/repository"
 	"github.com/FlyKarlik/gofemart/internal/usecase"
+	"github.com/FlyKarlik/gofemart/internal/worker"
+	"github.com/FlyKarlik/gofemart/pkg/accrual"
 	"github.com/FlyKarlik/gofemart/pkg/database"
+	"github.com/FlyKarlik/gofemart/pkg/jwt"
 	"github.com/FlyKarlik/gofemart/pkg/logger"
 	"github.com/FlyKarlik/gofemart/pkg/trace"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type AppGofemart struct {
@@ -55,14 +62,35 @@ func (a *AppGofemart) Start() error {
 	}
 	defer postgresConn.Close()
 
+	if err := prometheus.Register(metrics.NewPgxPoolCollector(postgresConn)); err != nil {
+		a.logger.Error("app[Gofemart]", "AppGofemart.Start[prometheus.Register]", "Failed to register pgxpool metrics", err)
+		return err
+	}
+
 	redisClient := database.NewRedisClient(&a.cfg.Infra.Redis)
 
-	repo := repository.New(a.logger, postgresConn, redisClient)
-	usecase := usecase.New(a.cfg, a.logger, repo)
+	jwtKeys, err := jwt.LoadKeySet(jwt.KeySetConfig{
+		SigningKeyFile:       a.cfg.AppGofemart.JWTSigningKeyFile,
+		VerificationKeyFiles: a.cfg.AppGofemart.JWTVerificationKeyFiles,
+		HMACSecret:           a.cfg.AppGofemart.JWTSecret,
+	})
+	if err != nil {
+		a.logger.Error("app[Gofemart]", "AppGofemart.Start[jwt.LoadKeySet]", "Failed to load jwt keys", err)
+		return err
+	}
+
+	repo := repository.New(a.cfg, a.logger, postgresConn, redisClient)
+	accrualClient := accrual.New(a.cfg.AppGofemart.AccrualSystemAddress)
+	usecase := usecase.New(a.cfg, a.logger, repo, accrualClient, jwtKeys)
+
+	healthChecker := health.New(a.cfg.AppGofemart.HealthCheckTimeout, a.cfg.AppGofemart.HealthCriticalChecks)
+	healthChecker.Register(health.CheckPostgres, health.PingPostgres(postgresConn))
+	healthChecker.Register(health.CheckRedis, health.PingRedis(redisClient))
+	healthChecker.Register(health.CheckAccrualBacklog, health.AccrualBacklog(usecase, a.cfg.AppGofemart.HealthAccrualBacklogMaxAge))
 
-	httpHandler := handler.New(a.logger, usecase)
+	httpHandler := handler.New(a.logger, usecase, healthChecker)
 	httpMiddleware := middleware.New(a.cfg, a.logger, usecase)
-	httpRouter := router.New(httpMiddleware, httpHandler)
+	httpRouter := router.New(a.cfg, httpMiddleware, httpHandler)
 	httpServer := server.New(a.cfg, a.logger, httpRouter, httpHandler)
 
 	go func() {
@@ -77,13 +105,44 @@ func (a *AppGofemart) Start() error {
 		}
 	}()
 
+	workerCtx, cancelWorker := context.WithCancel(ctx)
+	defer cancelWorker()
+
+	accrualWorker := worker.NewAccrualWorker(a.cfg, a.logger, usecase)
+
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		accrualWorker.Run(workerCtx)
+	}()
+
 	a.signalHandler(ctx)
-	if err := httpServer.Shuttdown(ctx); err != nil {
+
+	// Fail readiness first and give load balancers time to notice before
+	// the listener is closed.
+	healthChecker.SetShuttingDown()
+	time.Sleep(a.cfg.AppGofemart.ShutdownDrainDelay)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), a.cfg.AppGofemart.ShutdownTimeout)
+	defer cancelShutdown()
+
+	cancelWorker()
+
+	err = httpServer.Shuttdown(shutdownCtx)
+	if err != nil {
 		a.logger.Error("app[Gofemart]", "AppMigrator.Start[httpServer.Shuttdown]", "Failed while shuttdown http server", err)
-		return err
+		if err := httpServer.Close(); err != nil {
+			a.logger.Error("app[Gofemart]", "AppMigrator.Start[httpServer.Close]", "Failed to close http server", err)
+		}
+	}
+
+	select {
+	case <-workerDone:
+	case <-shutdownCtx.Done():
+		a.logger.Error("app[Gofemart]", "AppMigrator.Start[accrualWorker.Run]", "Accrual worker did not stop in time", shutdownCtx.Err())
 	}
 
-	return nil
+	return err
 }
 
 func (a *AppGofemart) signalHandler(ctx context.Context) {
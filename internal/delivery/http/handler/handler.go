@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"context"
+
+	"github.com/FlyKarlik/gofemart/internal/health"
 	"github.com/FlyKarlik/gofemart/internal/usecase"
 	"github.com/FlyKarlik/gofemart/pkg/logger"
 	"time"
@@ -9,13 +12,29 @@ import (
 type Handler struct {
 	logger  logger.Logger
 	usecase *usecase.Usecase
+	health  *health.Checker
 	startup time.Time
+
+	// streams is cancelled on server shutdown to end the event streams,
+	// which would otherwise keep Shutdown waiting for them.
+	streams      context.Context
+	closeStreams context.CancelFunc
 }
 
-func New(logger logger.Logger, usecase *usecase.Usecase) *Handler {
+func New(logger logger.Logger, usecase *usecase.Usecase, health *health.Checker) *Handler {
+	streams, closeStreams := context.WithCancel(context.Background())
 	return &Handler{
-		startup: time.Now(),
-		logger:  logger,
-		usecase: usecase,
+		startup:      time.Now(),
+		logger:       logger,
+		usecase:      usecase,
+		health:       health,
+		streams:      streams,
+		closeStreams: closeStreams,
 	}
 }
+
+// CloseStreams ends the open event streams, streams opened afterwards end
+// right away.
+func (h *Handler) CloseStreams() {
+	h.closeStreams()
+}
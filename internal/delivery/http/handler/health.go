@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/FlyKarlik/gofemart/internal/health"
+	"github.com/gin-gonic/gin"
+)
+
+// Live reports that the process is up and serving. It never checks
+// dependencies, so an outage of Postgres or Redis does not get pods restarted.
+func (h *Handler) Live(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Ready reports every dependency with its latency and answers 503 when a
+// critical one is down or the server is shutting down.
+func (h *Handler) Ready(c *gin.Context) {
+	report := h.health.Ready(c.Request.Context())
+
+	code := http.StatusOK
+	if report.Status == health.StatusDown {
+		h.logger.Warn("handler[health]", "Ready", "Service is not ready", nil, report.Checks)
+		code = http.StatusServiceUnavailable
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(code, report)
+}
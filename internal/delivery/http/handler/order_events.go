@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/response"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/gin-gonic/gin"
+)
+
+const orderEventsHeartbeat = 15 * time.Second
+
+// StreamOrderEvents pushes changes of user's orders as Server-Sent Events
+// @Summary Stream order events
+// @Description Keeps the connection open and sends an "order" event with the order state whenever one of the user's orders changes status or accrual. A comment line is sent every 15 seconds to keep proxies from closing the stream
+// @Tags Orders
+// @Security BearerAuth
+// @Produce text/event-stream
+// @Success 200 {object} model.OrderEvent "Stream of order events"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 500 {object} response.Problem "Internal server error"
+// @Router /api/user/orders/events [get]
+func (h *Handler) StreamOrderEvents(c *gin.Context) {
+	tracer := otel.Tracer("handler/stream-order-events")
+	ctx, span := tracer.Start(c.Request.Context(), "StreamOrderEvents")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("handler", "StreamOrderEvents"),
+		attribute.String("method", c.Request.Method),
+		attribute.String("path", c.FullPath()),
+	)
+
+	events, closeSubscription, err := h.usecase.SubscribeOrderEvents(ctx)
+	if err != nil {
+		h.logger.Error("handler[order-events]", "StreamOrderEvents", "Failed to subscribe to order events", err)
+		response.Error(c, err)
+		return
+	}
+	defer func() {
+		if err := closeSubscription(); err != nil {
+			h.logger.Warn("handler[order-events]", "StreamOrderEvents", "Failed to close subscription", err)
+		}
+	}()
+
+	// The stream outlives the server write timeout.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warn("handler[order-events]", "StreamOrderEvents", "Failed to disable write deadline", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(orderEventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.streams.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.Error("handler[order-events]", "StreamOrderEvents", "Failed to encode order event", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: order\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/usecase"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type fakeOrderEventsUsecase struct {
+	usecase.IUserUsecase
+	closed chan struct{}
+}
+
+func (f *fakeOrderEventsUsecase) SubscribeOrderEvents(ctx context.Context) (<-chan model.OrderEvent, func() error, error) {
+	return make(chan model.OrderEvent), func() error {
+		close(f.closed)
+		return nil
+	}, nil
+}
+
+func TestStreamOrderEventsEndsOnShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, err := logger.New("error")
+	if err != nil {
+		t.Fatalf("create logger: %v", err)
+	}
+
+	fake := &fakeOrderEventsUsecase{closed: make(chan struct{})}
+	h := New(log, &usecase.Usecase{IUserUsecase: fake}, nil)
+
+	router := gin.New()
+	router.GET("/events", h.StreamOrderEvents)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &http.Server{Handler: router}
+	srv.RegisterOnShutdown(h.CloseStreams)
+	go srv.Serve(listener) //nolint:errcheck
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/events")
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown with an open stream: %v", err)
+	}
+
+	select {
+	case <-fake.closed:
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not closed")
+	}
+
+	// The response is finished, reading it runs into its end.
+	if _, err := bufio.NewReader(resp.Body).ReadString(0); err == nil {
+		t.Error("stream still open after shutdown")
+	}
+}
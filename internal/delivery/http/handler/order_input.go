@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const maxOrderBodySize = 1 << 10
+
+var errEmptyOrderNumber = errors.New("empty order number")
+
+// bindOrderInput reads the order number from a JSON body when the request
+// says so, and from a plain text body otherwise, as the loyalty system
+// contract requires. A body over maxOrderBodySize fails with
+// errs.ErrRequestTooLarge instead of being cut to a different number.
+func bindOrderInput(c *gin.Context) (model.UserOrderInput, error) {
+	var input model.UserOrderInput
+
+	if c.ContentType() == binding.MIMEJSON {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxOrderBodySize)
+		err := c.ShouldBindJSON(&input)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return input, errs.ErrRequestTooLarge
+		}
+		return input, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxOrderBodySize+1))
+	if err != nil {
+		return input, err
+	}
+	if len(body) > maxOrderBodySize {
+		return input, errs.ErrRequestTooLarge
+	}
+
+	number := strings.TrimSpace(string(body))
+	if number == "" {
+		return input, errEmptyOrderNumber
+	}
+
+	input.Number = &number
+	return input, nil
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/gin-gonic/gin"
+)
+
+func TestBindOrderInputLimitsBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const number = "12345678903"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantNumber  string
+		wantErr     error
+	}{
+		{name: "plain text", contentType: "text/plain", body: number, wantNumber: number},
+		{name: "plain text at the limit", contentType: "text/plain", body: strings.Repeat(" ", maxOrderBodySize-len(number)) + number, wantNumber: number},
+		{name: "plain text over the limit", contentType: "text/plain", body: strings.Repeat(" ", maxOrderBodySize+1-len(number)) + number, wantErr: errs.ErrRequestTooLarge},
+		{name: "json", contentType: "application/json", body: `{"number":"` + number + `"}`, wantNumber: number},
+		{name: "json over the limit", contentType: "application/json", body: `{"number":"` + number + `"` + strings.Repeat(" ", maxOrderBodySize) + `}`, wantErr: errs.ErrRequestTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", tt.contentType)
+
+			input, err := bindOrderInput(c)
+			if tt.wantErr != nil {
+				if err != tt.wantErr {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bind: %v", err)
+			}
+			if input.Number == nil || *input.Number != tt.wantNumber {
+				t.Errorf("number = %v, want %s", input.Number, tt.wantNumber)
+			}
+		})
+	}
+}
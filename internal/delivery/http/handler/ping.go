@@ -31,5 +31,5 @@ func (h *Handler) Ping(c *gin.Context) {
 		DateTime: time.Now().Format(time.RFC1123),
 	}
 
-	response.New(c, http.StatusOK, true, resp, nil)
+	response.New(c, http.StatusOK, true, resp)
 }
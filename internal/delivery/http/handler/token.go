@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/response"
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshToken exchanges a refresh token for a new token pair
+// @Summary Refresh tokens
+// @Description Rotates the refresh token and issues a new access token. A refresh token can be used only once, reusing it revokes all tokens of the session
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param input body model.RefreshTokenInput true "Refresh token"
+// @Success 200 {object} response.BaseResponseLogin "New token pair"
+// @Failure 400 {object} response.Problem "Invalid request format"
+// @Failure 401 {object} response.Problem "Refresh token is invalid, expired or revoked"
+// @Failure 500 {object} response.Problem "Internal server error"
+// @Router /api/user/token/refresh [post]
+func (h *Handler) RefreshToken(c *gin.Context) {
+	tracer := otel.Tracer("handler/refresh-token")
+	ctx, span := tracer.Start(c.Request.Context(), "RefreshToken")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("handler", "RefreshToken"),
+		attribute.String("method", c.Request.Method),
+		attribute.String("path", c.FullPath()),
+	)
+
+	var input model.RefreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("handler[token]", "RefreshToken", "Failed to parse json object", err)
+		response.Error(c, errs.ErrInvalidRequest)
+		return
+	}
+
+	tokens, err := h.usecase.RefreshTokens(ctx, *input.RefreshToken)
+	if err != nil {
+		h.logger.Error("handler[token]", "RefreshToken", "Failed to refresh tokens", err)
+		response.Error(c, err)
+		return
+	}
+
+	response.New(c, http.StatusOK, true, tokens)
+}
+
+// LogoutUser revokes the current access token
+// @Summary Logout user
+// @Description Revokes the access token of the request. When a refresh token is passed, every token of its session is revoked too
+// @Tags Authentication
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body model.LogoutInput false "Refresh token to revoke"
+// @Success 200 {object} response.BaseResponseAny "Logged out"
+// @Failure 400 {object} response.Problem "Invalid request format"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 500 {object} response.Problem "Internal server error"
+// @Router /api/user/logout [post]
+func (h *Handler) LogoutUser(c *gin.Context) {
+	tracer := otel.Tracer("handler/logout-user")
+	ctx, span := tracer.Start(c.Request.Context(), "LogoutUser")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("handler", "LogoutUser"),
+		attribute.String("method", c.Request.Method),
+		attribute.String("path", c.FullPath()),
+	)
+
+	var input model.LogoutInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			h.logger.Error("handler[token]", "LogoutUser", "Failed to parse json object", err)
+			response.Error(c, errs.ErrInvalidRequest)
+			return
+		}
+	}
+
+	if err := h.usecase.LogoutUser(ctx, input); err != nil {
+		h.logger.Error("handler[token]", "LogoutUser", "Failed to logout user", err)
+		response.Error(c, err)
+		return
+	}
+
+	response.New[any](c, http.StatusOK, true, nil)
+}
+
+// GetJWKS publishes the public keys access tokens are signed with, in the
+// plain RFC 7517 format other services expect.
+func (h *Handler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.usecase.GetJWKS())
+}
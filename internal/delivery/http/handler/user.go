@@ -21,9 +21,10 @@ import (
 // @Produce json
 // @Param input body model.UserInput true "Registration data"
 // @Success 200 {object} response.BaseResponseAny "Successfully processed request"
-// @Failure 400 {object} response.BaseResponseAny "Bad request - invalid input params"
-// @Failure 409 {object} response.BaseResponseAny "Conflict - user login in use"
-// @Failure 500 {object} response.BaseResponseAny "Internal system error"
+// @Failure 400 {object} response.Problem "Bad request - invalid input params"
+// @Failure 409 {object} response.Problem "Conflict - user login in use"
+// @Failure 422 {object} response.Problem "Password breaks the password policy, see errors"
+// @Failure 500 {object} response.Problem "Internal system error"
 // @Router /api/user/register [post]
 func (h *Handler) RegisterUser(c *gin.Context) {
 	tracer := otel.Tracer("handler/register-user")
@@ -39,34 +40,32 @@ func (h *Handler) RegisterUser(c *gin.Context) {
 	var input model.UserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.logger.Error("handler[user]", "RegisterUser", "Failed to parse json object", err)
-		response.New[any](c, http.StatusBadRequest, false, nil, errs.ErrInvalidRequest)
+		response.Error(c, errs.ErrInvalidRequest)
 		return
 	}
 
 	if err := h.usecase.RegisterUser(ctx, input); err != nil {
 		h.logger.Error("handler[user]", "RegisterUser", "Failed to register user", err)
-		response.New[any](c, status.HTTPStatusFromError(err), false, nil, err)
+		response.Error(c, err)
 		return
 	}
 
-	response.New[any](c, http.StatusOK, true, nil, nil)
-}
-
-type loginUserResponse struct {
-	Token string `json:"token"`
+	response.New[any](c, http.StatusOK, true, nil)
 }
 
 // LoginUser authenticates a user and returns an access token
 // @Summary Authenticate user
-// @Description Verifies user credentials and returns JWT token
+// @Description Verifies user credentials and returns JWT access token with a refresh token
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param credentials body model.UserInput true "Login credentials"
 // @Success 200 {object} response.BaseResponseLogin "Successful authentication"
-// @Failure 400 {object} response.BaseResponseAny "Invalid request format"
-// @Failure 401 {object} response.BaseResponseAny "Authentication failed"
-// @Failure 500 {object} response.BaseResponseAny "Server error"
+// @Failure 400 {object} response.Problem "Invalid request format"
+// @Failure 401 {object} response.Problem "Authentication failed"
+// @Failure 429 {object} response.Problem "Too many failed attempts, retry after the Retry-After header"
+// @Header 429 {integer} Retry-After "Seconds until the next attempt is allowed"
+// @Failure 500 {object} response.Problem "Server error"
 // @Router /api/user/login [post]
 func (h *Handler) LoginUser(c *gin.Context) {
 	tracer := otel.Tracer("handler/login-user")
@@ -82,35 +81,82 @@ func (h *Handler) LoginUser(c *gin.Context) {
 	var input model.UserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.logger.Error("handler[user]", "LoginUser", "Failed to parse json object", err)
-		response.New[any](c, http.StatusBadRequest, false, nil, errs.ErrInvalidRequest)
+		response.Error(c, errs.ErrInvalidRequest)
 		return
 	}
 
-	token, err := h.usecase.LoginUser(ctx, input)
+	tokens, err := h.usecase.LoginUser(ctx, input, c.ClientIP())
 	if err != nil {
 		h.logger.Error("handler[user]", "LoginUser", "Failed to login user", err)
-		response.New[any](c, status.HTTPStatusFromError(err), false, nil, err)
+		response.Error(c, err)
+		return
+	}
+
+	response.New(c, http.StatusOK, true, tokens)
+}
+
+// ChangePassword replaces the password of the current user
+// @Summary Change password
+// @Description Replaces the password after checking the current one and revokes every access and refresh token of the user, so all sessions have to log in again
+// @Tags Authentication
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body model.ChangePasswordInput true "Current and new password"
+// @Success 200 {object} response.BaseResponseAny "Password changed"
+// @Failure 400 {object} response.Problem "Invalid request format"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 403 {object} response.Problem "Current password is wrong"
+// @Failure 422 {object} response.Problem "New password breaks the password policy, see errors"
+// @Failure 429 {object} response.Problem "Too many failed attempts, retry after the Retry-After header"
+// @Header 429 {integer} Retry-After "Seconds until the next attempt is allowed"
+// @Failure 500 {object} response.Problem "Internal server error"
+// @Router /api/user/password [put]
+func (h *Handler) ChangePassword(c *gin.Context) {
+	tracer := otel.Tracer("handler/change-password")
+	ctx, span := tracer.Start(c.Request.Context(), "ChangePassword")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("handler", "ChangePassword"),
+		attribute.String("method", c.Request.Method),
+		attribute.String("path", c.FullPath()),
+	)
+
+	var input model.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("handler[user]", "ChangePassword", "Failed to parse json object", err)
+		response.Error(c, errs.ErrInvalidRequest)
 		return
 	}
 
-	response.New(c, http.StatusOK, true, loginUserResponse{Token: token}, nil)
+	if err := h.usecase.ChangePassword(ctx, input, c.ClientIP()); err != nil {
+		h.logger.Error("handler[user]", "ChangePassword", "Failed to change password", err)
+		response.Error(c, err)
+		return
+	}
+
+	response.New[any](c, http.StatusOK, true, nil)
 }
 
 // CreateOrder uploads a new order number for processing
 // @Summary Upload user order
-// @Description Accepts a plain text order number and processes it
+// @Description Accepts an order number as a text/plain body, or as JSON {"number": "..."}, and queues it for accrual
 // @Tags Orders
 // @Security BearerAuth
+// @Accept plain
 // @Accept json
 // @Produce json
-// @Param order body model.UserOrderInput true "Order number"
+// @Param order body string true "Order number"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
 // @Success 200 {object} response.BaseResponseAny "Order already uploaded by this user"
-// @Success 202 {object} nil "New order accepted for processing"
-// @Failure 400 {object} response.BaseResponseAny "Invalid request format"
-// @Failure 401 {object} response.BaseResponseAny "Unauthorized"
-// @Failure 409 {object} response.BaseResponseAny "Order already uploaded by another user"
-// @Failure 422 {object} response.BaseResponseAny "Invalid order number format"
-// @Failure 500 {object} response.BaseResponseAny "Internal server error"
+// @Success 202 {object} response.BaseResponseAny "New order accepted for processing"
+// @Failure 400 {object} response.Problem "Invalid request format"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 409 {object} response.Problem "Order already uploaded by another user"
+// @Failure 413 {object} response.Problem "Request body too large"
+// @Failure 422 {object} response.Problem "Invalid order number format"
+// @Failure 500 {object} response.Problem "Internal server error"
 // @Router /api/user/orders [post]
 func (h *Handler) CreateOrder(c *gin.Context) {
 	tracer := otel.Tracer("handler/create-order")
@@ -123,44 +169,55 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 		attribute.String("path", c.FullPath()),
 	)
 
-	var input model.UserOrderInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		h.logger.Error("handler[user]", "CreateOrder", "Failed to parse JSON body", err)
-		response.New[any](c, http.StatusBadRequest, false, nil, errs.ErrInvalidRequest)
+	input, err := bindOrderInput(c)
+	if err != nil {
+		h.logger.Error("handler[user]", "CreateOrder", "Failed to parse request body", err)
+		if status.CodeFromError(err) == errs.CodeRequestTooLarge {
+			response.Error(c, err)
+			return
+		}
+		response.Error(c, errs.ErrInvalidRequest)
 		return
 	}
 
 	if !isValidOrderNumber(*input.Number) {
 		h.logger.Error("handler[user]", "CreateOrder", "Failed to validate order number", errs.ErrInvalidOrderNumber)
-		response.New[any](c, http.StatusUnprocessableEntity, false, nil, errs.ErrInvalidOrderNumber)
+		response.Error(c, errs.ErrInvalidOrderNumber)
 		return
 	}
 
-	err := h.usecase.CreateUserOrder(ctx, input)
+	err = h.usecase.CreateUserOrder(ctx, input)
 	if err != nil {
 		if status.CodeFromError(err) == errs.CodeOrderAlreadyUpload {
-			response.New[any](c, http.StatusOK, true, nil, nil)
+			response.New[any](c, http.StatusOK, true, nil)
 			return
 		}
 		h.logger.Error("handler[user]", "CreateOrder", "Failed to create order", err)
-		response.New[any](c, status.HTTPStatusFromError(err), false, nil, err)
+		response.Error(c, err)
 		return
 	}
 
-	response.New[any](c, http.StatusAccepted, true, nil, nil)
+	response.New[any](c, http.StatusAccepted, true, nil)
 }
 
 // GetUserOrders returns a list of user's uploaded orders
 // @Summary Get user's orders
-// @Description Retrieves all orders uploaded by the authenticated user
+// @Description Retrieves orders uploaded by the authenticated user, one page at a time
 // @Tags Orders
 // @Security BearerAuth
 // @Accept json
 // @Produce json
+// @Param limit query int false "Page size, 1 to 1000" default(100)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param from query string false "Lower time bound, inclusive, RFC 3339"
+// @Param to query string false "Upper time bound, exclusive, RFC 3339"
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(asc)
+// @Param status query []string false "Order statuses to include" collectionFormat(multi) Enums(NEW, PROCESSING, INVALID, PROCESSED)
 // @Success 200 {object} response.BaseResponseOrders "Successful response with orders"
 // @Success 204 {object} nil "No orders found for user"
-// @Failure 401 {object} response.BaseResponseAny "Unauthorized"
-// @Failure 500 {object} response.BaseResponseAny "Internal server error"
+// @Failure 400 {object} response.Problem "Invalid listing parameters"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 500 {object} response.Problem "Internal server error"
 // @Router /api/user/orders [get]
 func (h *Handler) GetUserOrders(c *gin.Context) {
 	tracer := otel.Tracer("handler/get-user-orders")
@@ -173,14 +230,25 @@ func (h *Handler) GetUserOrders(c *gin.Context) {
 		attribute.String("path", c.FullPath()),
 	)
 
-	orders, err := h.usecase.GetUserOrders(ctx)
+	var query model.OrderListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.logger.Error("handler[user]", "GetUserOrders", "Failed to parse query", err)
+		response.Error(c, errs.ErrInvalidRequest)
+		return
+	}
+
+	page, err := h.usecase.GetUserOrders(ctx, query)
 	if err != nil {
+		if status.CodeFromError(err) == errs.CodeNoOrders {
+			response.NoContent(c)
+			return
+		}
 		h.logger.Error("handler[user]", "GetUserOrders", "Failed to get user orders", err)
-		response.New[any](c, status.HTTPStatusFromError(err), false, nil, err)
+		response.Error(c, err)
 		return
 	}
 
-	response.New(c, http.StatusOK, true, orders, nil)
+	response.NewPage(c, http.StatusOK, page.Items, page.NextCursor)
 }
 
 // GetUserBalance returns the current balance and total withdrawn amount
@@ -191,8 +259,8 @@ func (h *Handler) GetUserOrders(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Success 200 {object} response.BaseResponseBalance "Successful response with balance data"
-// @Failure 401 {object} response.BaseResponseAny "Unauthorized"
-// @Failure 500 {object} response.BaseResponseAny "Internal server error"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 500 {object} response.Problem "Internal server error"
 // @Router /api/user/balance [get]
 func (h *Handler) GetUserBalance(c *gin.Context) {
 	tracer := otel.Tracer("handler/get-user-balance")
@@ -208,11 +276,11 @@ func (h *Handler) GetUserBalance(c *gin.Context) {
 	balance, err := h.usecase.GetUserBalance(ctx)
 	if err != nil {
 		h.logger.Error("handler[user]", "GetUserBalance", "Failed to get user balance", err)
-		response.New[any](c, status.HTTPStatusFromError(err), false, nil, err)
+		response.Error(c, err)
 		return
 	}
 
-	response.New(c, http.StatusOK, true, balance, nil)
+	response.New(c, http.StatusOK, true, balance)
 }
 
 // WithdrawUserBalance withdraws funds from the user's balance
@@ -223,12 +291,14 @@ func (h *Handler) GetUserBalance(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param withdrawal body response.WitdrawalUserBalanceInput true "Withdrawal request"
+// @Param Idempotency-Key header string false "Key to safely retry the request"
 // @Success 200 {object} response.BaseResponseAny "Withdrawal successful"
-// @Failure 400 {object} response.BaseResponseAny "Invalid request format"
-// @Failure 401 {object} response.BaseResponseAny "Unauthorized"
-// @Failure 402 {object} response.BaseResponseAny "Insufficient funds"
-// @Failure 422 {object} response.BaseResponseAny "Invalid order number format"
-// @Failure 500 {object} response.BaseResponseAny "Internal server error"
+// @Failure 400 {object} response.Problem "Invalid request format"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 402 {object} response.Problem "Insufficient funds"
+// @Failure 409 {object} response.Problem "Request with the same idempotency key is in progress"
+// @Failure 422 {object} response.Problem "Invalid order number format or idempotency key reused with another request"
+// @Failure 500 {object} response.Problem "Internal server error"
 // @Router /api/user/balance/withdraw [post]
 func (h *Handler) WithdrawUserBalance(c *gin.Context) {
 	tracer := otel.Tracer("handler/withdraw-user-balance")
@@ -241,39 +311,45 @@ func (h *Handler) WithdrawUserBalance(c *gin.Context) {
 		attribute.String("path", c.FullPath()),
 	)
 
-	var input model.UserWithdrawalInput[float64]
+	var input model.UserWithdrawalInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.logger.Error("handler[user]", "WithdrawUserBalance", "Failed to parse JSON body", err)
-		response.New[any](c, http.StatusBadRequest, false, nil, errs.ErrInvalidRequest)
+		response.Error(c, errs.ErrInvalidRequest)
 		return
 	}
 
 	if !isValidOrderNumber(*input.OrderNumber) {
 		h.logger.Error("handler[user]", "WithdrawUserBalance", "Failed to validate order number", errs.ErrInvalidOrderNumber)
-		response.New[any](c, http.StatusUnprocessableEntity, false, nil, errs.ErrInvalidOrderNumber)
+		response.Error(c, errs.ErrInvalidOrderNumber)
 		return
 	}
 
 	if err := h.usecase.WithdrawUserBalance(ctx, input); err != nil {
 		h.logger.Error("handler[user]", "WithdrawUserBalance", "Failed to withdraw user balance", err)
-		response.New[any](c, status.HTTPStatusFromError(err), false, nil, err)
+		response.Error(c, err)
 		return
 	}
 
-	response.New[any](c, http.StatusOK, true, nil, nil)
+	response.New[any](c, http.StatusOK, true, nil)
 }
 
 // GetUserWithdrawals returns user's withdrawal history
 // @Summary Get user withdrawals
-// @Description Retrieves a list of all user's balance withdrawals
+// @Description Retrieves user's balance withdrawals, one page at a time
 // @Tags Balance
 // @Security BearerAuth
 // @Accept json
 // @Produce json
+// @Param limit query int false "Page size, 1 to 1000" default(100)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param from query string false "Lower time bound, inclusive, RFC 3339"
+// @Param to query string false "Upper time bound, exclusive, RFC 3339"
+// @Param sort query string false "Sort direction by time" Enums(asc, desc) default(asc)
 // @Success 200 {object} response.BaseResponseWithdrawals "Successful response with withdrawal history"
 // @Success 204 {object} nil "No withdrawals found"
-// @Failure 401 {object} response.BaseResponseAny "Unauthorized"
-// @Failure 500 {object} response.BaseResponseAny "Internal server error"
+// @Failure 400 {object} response.Problem "Invalid listing parameters"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 500 {object} response.Problem "Internal server error"
 // @Router /api/user/withdrawals [get]
 func (h *Handler) GetUserWithdrawals(c *gin.Context) {
 	tracer := otel.Tracer("handler/get-user-withdrawals")
@@ -286,16 +362,55 @@ func (h *Handler) GetUserWithdrawals(c *gin.Context) {
 		attribute.String("path", c.FullPath()),
 	)
 
-	withdrawals, err := h.usecase.GetUserWithdrawals(ctx)
+	var query model.ListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.logger.Error("handler[user]", "GetUserWithdrawals", "Failed to parse query", err)
+		response.Error(c, errs.ErrInvalidRequest)
+		return
+	}
+
+	page, err := h.usecase.GetUserWithdrawals(ctx, query)
 	if err != nil {
 		if status.CodeFromError(err) == errs.CodeNooneWithdrawal {
-			response.New[any](c, http.StatusNoContent, true, nil, nil)
+			response.NoContent(c)
 			return
 		}
 		h.logger.Error("handler[user]", "WithdrawUserBalance", "Failed to withdraw user balance", err)
-		response.New[any](c, status.HTTPStatusFromError(err), false, nil, err)
+		response.Error(c, err)
+		return
+	}
+
+	response.NewPage(c, http.StatusOK, page.Items, page.NextCursor)
+}
+
+// GetUserLedger returns the audit trail of user's balance
+// @Summary Get user balance ledger
+// @Description Retrieves every posting that moved the user's points, oldest first, with running totals
+// @Tags Balance
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.BaseResponseLedger "Successful response with ledger entries"
+// @Failure 401 {object} response.Problem "Unauthorized"
+// @Failure 500 {object} response.Problem "Internal server error"
+// @Router /api/user/balance/ledger [get]
+func (h *Handler) GetUserLedger(c *gin.Context) {
+	tracer := otel.Tracer("handler/get-user-ledger")
+	ctx, span := tracer.Start(c.Request.Context(), "GetUserLedger")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("handler", "GetUserLedger"),
+		attribute.String("method", c.Request.Method),
+		attribute.String("path", c.FullPath()),
+	)
+
+	ledger, err := h.usecase.GetUserLedger(ctx)
+	if err != nil {
+		h.logger.Error("handler[user]", "GetUserLedger", "Failed to get user ledger", err)
+		response.Error(c, err)
 		return
 	}
 
-	response.New(c, http.StatusOK, true, withdrawals, nil)
+	response.New(c, http.StatusOK, true, ledger)
 }
@@ -6,6 +6,10 @@ import (
 )
 
 func isValidOrderNumber(number string) bool {
+	if number == "" {
+		return false
+	}
+
 	var sum int
 	var alt bool
 
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/response"
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLength   = 255
+
+	// idempotencyMaxBodySize bounds the body read into memory to fingerprint
+	// the request.
+	idempotencyMaxBodySize = 1 << 20
+
+	// idempotencyStoreTimeout bounds storing or releasing the key once the
+	// handler is done, which must not depend on the client still waiting.
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the stored response for repeated requests carrying the
+// same Idempotency-Key header. It must run after Identity, keys are scoped to
+// the authenticated user. Requests without the header pass through.
+func (m *Middleware) Idempotency(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+
+	if len(key) > idempotencyKeyMaxLength {
+		m.logger.Error("middleware", "Idempotency", "Idempotency key is too long", errs.ErrInvalidRequest)
+		response.Error(c, errs.ErrInvalidRequest)
+		c.Abort()
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, idempotencyMaxBodySize))
+	if err != nil {
+		m.logger.Error("middleware", "Idempotency", "Failed to read request body", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.Error(c, errs.ErrRequestTooLarge)
+		} else {
+			response.Error(c, errs.ErrInvalidRequest)
+		}
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx := c.Request.Context()
+	fingerprint := requestFingerprint(c.Request.Method, c.FullPath(), body)
+
+	record, err := m.usecase.ReserveIdempotencyKey(ctx, key, fingerprint)
+	if err != nil {
+		m.logger.Error("middleware", "Idempotency", "Failed to reserve idempotency key", err)
+		response.Error(c, err)
+		c.Abort()
+		return
+	}
+
+	if record != nil {
+		c.Header(idempotencyReplayedHeader, "true")
+		c.Data(record.StatusCode, record.ContentType, record.Body)
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+	c.Writer = recorder
+
+	// The key stays reserved until it is completed or released. Neither may
+	// be skipped because the client went away or the handler panicked, or
+	// retries are refused until the key expires.
+	defer func() {
+		if r := recover(); r != nil {
+			m.releaseIdempotencyKey(ctx, key)
+			panic(r)
+		}
+	}()
+
+	c.Next()
+
+	if recorder.Status() >= http.StatusInternalServerError {
+		m.releaseIdempotencyKey(ctx, key)
+		return
+	}
+
+	m.completeIdempotencyKey(ctx, key, model.IdempotencyRecord{
+		Fingerprint: fingerprint,
+		StatusCode:  recorder.Status(),
+		ContentType: recorder.Header().Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+	})
+}
+
+func (m *Middleware) completeIdempotencyKey(ctx context.Context, key string, record model.IdempotencyRecord) {
+	ctx, cancel := idempotencyStoreContext(ctx)
+	defer cancel()
+
+	if err := m.usecase.CompleteIdempotencyKey(ctx, key, record); err != nil {
+		m.logger.Error("middleware", "Idempotency", "Failed to complete idempotency key", err)
+	}
+}
+
+func (m *Middleware) releaseIdempotencyKey(ctx context.Context, key string) {
+	ctx, cancel := idempotencyStoreContext(ctx)
+	defer cancel()
+
+	if err := m.usecase.ReleaseIdempotencyKey(ctx, key); err != nil {
+		m.logger.Error("middleware", "Idempotency", "Failed to release idempotency key", err)
+	}
+}
+
+// idempotencyStoreContext keeps the values of the request context, the key
+// is scoped to its user, but not its cancellation.
+func idempotencyStoreContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), idempotencyStoreTimeout)
+}
+
+func requestFingerprint(method string, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/usecase"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type fakeIdempotencyUsecase struct {
+	reserved    int
+	completed   []error
+	released    []error
+	completedAs []model.IdempotencyRecord
+}
+
+func (f *fakeIdempotencyUsecase) ReserveIdempotencyKey(ctx context.Context, key string, fingerprint string) (*model.IdempotencyRecord, error) {
+	f.reserved++
+	return nil, nil
+}
+
+func (f *fakeIdempotencyUsecase) CompleteIdempotencyKey(ctx context.Context, key string, record model.IdempotencyRecord) error {
+	f.completed = append(f.completed, ctx.Err())
+	f.completedAs = append(f.completedAs, record)
+	return ctx.Err()
+}
+
+func (f *fakeIdempotencyUsecase) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	f.released = append(f.released, ctx.Err())
+	return ctx.Err()
+}
+
+func TestIdempotencyStoresKeyAfterRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, err := logger.New("error")
+	if err != nil {
+		t.Fatalf("create logger: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		handler       func(c *gin.Context, cancel context.CancelFunc)
+		wantPanic     bool
+		wantCompleted int
+		wantReleased  int
+	}{
+		{
+			name: "completes",
+			handler: func(c *gin.Context, cancel context.CancelFunc) {
+				c.String(http.StatusAccepted, "accepted")
+			},
+			wantCompleted: 1,
+		},
+		{
+			name: "completes after client disconnected",
+			handler: func(c *gin.Context, cancel context.CancelFunc) {
+				cancel()
+				c.String(http.StatusAccepted, "accepted")
+			},
+			wantCompleted: 1,
+		},
+		{
+			name: "releases on server error",
+			handler: func(c *gin.Context, cancel context.CancelFunc) {
+				cancel()
+				c.String(http.StatusInternalServerError, "failed")
+			},
+			wantReleased: 1,
+		},
+		{
+			name: "releases on panic",
+			handler: func(c *gin.Context, cancel context.CancelFunc) {
+				cancel()
+				panic("handler failed")
+			},
+			wantPanic:    true,
+			wantReleased: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeIdempotencyUsecase{}
+			m := New(nil, log, &usecase.Usecase{IIdempotencyUsecase: fake})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			router := gin.New()
+			router.POST("/orders", m.Idempotency, func(c *gin.Context) {
+				tt.handler(c, cancel)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("12345678903")).WithContext(ctx)
+			req.Header.Set(idempotencyKeyHeader, "key")
+
+			panicked := func() (panicked bool) {
+				defer func() {
+					panicked = recover() != nil
+				}()
+				router.ServeHTTP(httptest.NewRecorder(), req)
+				return false
+			}()
+
+			if panicked != tt.wantPanic {
+				t.Errorf("panicked = %v, want %v", panicked, tt.wantPanic)
+			}
+			if len(fake.completed) != tt.wantCompleted {
+				t.Errorf("completed %d times, want %d", len(fake.completed), tt.wantCompleted)
+			}
+			if len(fake.released) != tt.wantReleased {
+				t.Errorf("released %d times, want %d", len(fake.released), tt.wantReleased)
+			}
+			for _, err := range append(fake.completed, fake.released...) {
+				if err != nil {
+					t.Errorf("key stored with a done context: %v", err)
+				}
+			}
+			for _, record := range fake.completedAs {
+				if record.StatusCode != http.StatusAccepted || string(record.Body) != "accepted" {
+					t.Errorf("stored response = %d %q, want %d %q", record.StatusCode, record.Body, http.StatusAccepted, "accepted")
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyRejectsOversizedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, err := logger.New("error")
+	if err != nil {
+		t.Fatalf("create logger: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		size         int
+		wantStatus   int
+		wantReserved int
+	}{
+		{name: "at the limit", size: idempotencyMaxBodySize, wantStatus: http.StatusAccepted, wantReserved: 1},
+		{name: "over the limit", size: idempotencyMaxBodySize + 1, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeIdempotencyUsecase{}
+			m := New(nil, log, &usecase.Usecase{IIdempotencyUsecase: fake})
+
+			router := gin.New()
+			router.POST("/orders", m.Idempotency, func(c *gin.Context) {
+				c.String(http.StatusAccepted, "accepted")
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(strings.Repeat("1", tt.size)))
+			req.Header.Set(idempotencyKeyHeader, "key")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if fake.reserved != tt.wantReserved {
+				t.Errorf("reserved %d times, want %d", fake.reserved, tt.wantReserved)
+			}
+		})
+	}
+}
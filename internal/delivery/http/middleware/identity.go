@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"context"
-	"net/http"
 
 	"github.com/FlyKarlik/gofemart/internal/delivery/http/response"
 	"github.com/FlyKarlik/gofemart/internal/errs"
@@ -16,32 +15,69 @@ func (m *Middleware) Identity(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
 		m.logger.Error("middleware", "Identity", "Failed to get auth header", errs.ErrEmptyAuthHeader)
-		response.New[any](c, http.StatusUnauthorized, false, nil, errs.ErrUnauthorized)
+		response.Error(c, errs.ErrUnauthorized)
+		c.Abort()
 		return
 	}
 
 	token, err := jwt.GetClearToken(authHeader)
 	if err != nil {
 		m.logger.Error("middleware", "Identity", "Failed to get validated token", errs.ErrInvalidToken)
-		response.New[any](c, http.StatusUnauthorized, false, nil, errs.ErrUnauthorized)
+		response.Error(c, errs.ErrUnauthorized)
+		c.Abort()
 		return
 	}
 
-	claims, err := jwt.ParseToken(token, m.cfg.AppGofemart.JWTSecret)
+	claims, err := m.usecase.ParseAccessToken(token)
 	if err != nil {
 		m.logger.Error("middleware", "Identity", "Failed to parse token", errs.ErrInvalidToken)
-		response.New[any](c, http.StatusUnauthorized, false, nil, errs.ErrUnauthorized)
+		response.Error(c, errs.ErrUnauthorized)
+		c.Abort()
 		return
 	}
 
-	user, err := m.usecase.GetUserByID(c.Request.Context(), uuid.MustParse(claims.UserID))
+	// Tokens without jti cannot be revoked, so they are not accepted.
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		m.logger.Error("middleware", "Identity", "Token has no id or expiration", errs.ErrInvalidToken)
+		response.Error(c, errs.ErrUnauthorized)
+		c.Abort()
+		return
+	}
+
+	revoked, err := m.usecase.IsAccessTokenRevoked(c.Request.Context(), claims)
+	if err != nil {
+		m.logger.Error("middleware", "Identity", "Failed to check token revocation", err)
+		response.Error(c, err)
+		c.Abort()
+		return
+	}
+
+	if revoked {
+		m.logger.Error("middleware", "Identity", "Token is revoked", errs.ErrInvalidToken)
+		response.Error(c, errs.ErrUnauthorized)
+		c.Abort()
+		return
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		m.logger.Error("middleware", "Identity", "Failed to parse user id", errs.ErrInvalidToken)
+		response.Error(c, errs.ErrUnauthorized)
+		c.Abort()
+		return
+	}
+
+	user, err := m.usecase.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		m.logger.Error("middleware", "Identity", "Failed to get user by id", err)
-		response.New[any](c, http.StatusUnauthorized, false, nil, errs.ErrUnauthorized)
+		response.Error(c, errs.ErrUnauthorized)
+		c.Abort()
 		return
 	}
 
 	ctx := context.WithValue(c.Request.Context(), model.ContextKeyEnumUserID, *user.ID)
+	ctx = context.WithValue(ctx, model.ContextKeyEnumTokenID, claims.ID)
+	ctx = context.WithValue(ctx, model.ContextKeyEnumTokenExpiresAt, claims.ExpiresAt.Time)
 	c.Request = c.Request.WithContext(ctx)
 	c.Next()
 }
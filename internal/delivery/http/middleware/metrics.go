@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records request latency labelled by the route pattern, so paths
+// with parameters do not blow up the label cardinality. Event streams are
+// left out, their duration is the lifetime of the connection.
+func (m *Middleware) Metrics(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	if strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+		return
+	}
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	metrics.HTTPRequestDuration.
+		WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+		Observe(time.Since(start).Seconds())
+}
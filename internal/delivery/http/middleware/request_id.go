@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	requestIDMaxLength = 128
+)
+
+// RequestID keeps the X-Request-ID of the caller, or assigns a new one, and
+// echoes it in the response so errors can be correlated with logs.
+func (m *Middleware) RequestID(c *gin.Context) {
+	requestID := c.GetHeader(requestIDHeader)
+	if requestID == "" || len(requestID) > requestIDMaxLength {
+		requestID = uuid.NewString()
+	}
+
+	c.Header(requestIDHeader, requestID)
+	ctx := context.WithValue(c.Request.Context(), model.ContextKeyEnumRequestID, requestID)
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
@@ -0,0 +1,87 @@
+package response
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/status"
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypeBase    = "/problems/"
+)
+
+// Problem is an RFC 7807 error response. Code is the stable name of the
+// errs.CodeEnum, clients should match on it rather than on Title or Detail.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+	// Errors lists the rejected input fields of a VALIDATION_FAILED problem.
+	Errors []errs.FieldError `json:"errors,omitempty"`
+}
+
+// Error writes err as application/problem+json with the status
+// status.HTTPStatusFromError assigns to it. Errors that are not
+// *errs.CustomError are reported as unknown without their text.
+func Error(c *gin.Context, err error) {
+	code := status.HTTPStatusFromError(err)
+	if code == http.StatusNoContent {
+		NoContent(c)
+		return
+	}
+
+	if retryAfter := status.RetryAfterFromError(err); retryAfter != "" {
+		c.Header("Retry-After", retryAfter)
+	}
+
+	problem := newProblem(c, code, err)
+	c.Header("Content-Type", problemContentType)
+	c.JSON(code, problem)
+}
+
+// NoContent answers with 204 and no body.
+func NoContent(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+func newProblem(c *gin.Context, code int, err error) *Problem {
+	errCode := errs.CodeEnum(errs.CodeUnknown)
+	detail := ""
+	var fields []errs.FieldError
+	if customErr, ok := err.(*errs.CustomError); ok {
+		errCode = customErr.Code
+		detail = customErr.Message
+		fields = customErr.Fields
+	}
+
+	problem := &Problem{
+		Type:     problemTypeBase + strings.ToLower(strings.ReplaceAll(errCode.String(), "_", "-")),
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     errCode.String(),
+		Errors:   fields,
+	}
+
+	ctx := c.Request.Context()
+	if requestID, ok := ctx.Value(model.ContextKeyEnumRequestID).(string); ok {
+		problem.RequestID = requestID
+	}
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		problem.TraceID = spanCtx.TraceID().String()
+	}
+
+	return problem
+}
@@ -4,23 +4,36 @@ import (
 	"time"
 
 	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/money"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// BaseResponse is the envelope of successful responses, errors are written
+// by Error as problem details.
 type BaseResponse[T any] struct {
-	Status bool  `json:"status"`
-	Code   int   `json:"code"`
-	Data   T     `json:"data,omitempty"`
-	Error  error `json:"error,omitempty"`
+	Status     bool   `json:"status"`
+	Code       int    `json:"code"`
+	Data       T      `json:"data,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
-func New[T any](c *gin.Context, code int, status bool, data T, err error) {
+func New[T any](c *gin.Context, code int, status bool, data T) {
 	obj := &BaseResponse[T]{
 		Code:   code,
 		Status: status,
 		Data:   data,
-		Error:  err,
+	}
+	c.JSON(code, obj)
+}
+
+// NewPage writes one page of a listing. nextCursor is empty on the last page.
+func NewPage[T any](c *gin.Context, code int, data []T, nextCursor string) {
+	obj := &BaseResponse[[]T]{
+		Code:       code,
+		Status:     true,
+		Data:       data,
+		NextCursor: nextCursor,
 	}
 	c.JSON(code, obj)
 }
@@ -30,54 +43,72 @@ type BaseResponseAny struct {
 	Status bool        `json:"status"`
 	Code   int         `json:"code"`
 	Data   interface{} `json:"data,omitempty"`
-	Error  string      `json:"error,omitempty"`
 }
 
 type BaseResponseLogin struct {
 	Status bool `json:"status"`
 	Code   int  `json:"code"`
 	Data   struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	} `json:"data,omitempty"`
-	Error string `json:"error,omitempty"`
 }
 
 type BaseResponseOrders struct {
-	Status bool              `json:"status"`
-	Code   int               `json:"code"`
-	Data   []model.UserOrder `json:"data,omitempty"`
-	Error  string            `json:"error,omitempty"`
+	Status     bool              `json:"status"`
+	Code       int               `json:"code"`
+	Data       []model.UserOrder `json:"data,omitempty"`
+	NextCursor string            `json:"next_cursor,omitempty"`
 }
 
 type BaseResponseBalance struct {
 	Status bool               `json:"status"`
 	Code   int                `json:"code"`
 	Data   UserBalanceBalance `json:"data,omitempty"`
-	Error  string             `json:"error,omitempty"`
 }
 
 type UserBalanceBalance struct {
-	UserID    *uuid.UUID `json:"user_id,omitempty"`
-	Current   *float64   `json:"current,omitempty"`
-	Withdrawn *float64   `json:"withdrawn,omitempty"`
+	UserID    *uuid.UUID    `json:"user_id,omitempty"`
+	Current   *money.Amount `json:"current,omitempty" swaggertype:"number"`
+	Withdrawn *money.Amount `json:"withdrawn,omitempty" swaggertype:"number"`
 }
 
 type BaseResponseWithdrawals struct {
-	Status bool                 `json:"status"`
-	Code   int                  `json:"code"`
-	Data   []UserWithdrawalData `json:"data,omitempty"`
-	Error  string               `json:"error,omitempty"`
+	Status     bool                 `json:"status"`
+	Code       int                  `json:"code"`
+	Data       []UserWithdrawalData `json:"data,omitempty"`
+	NextCursor string               `json:"next_cursor,omitempty"`
 }
 
 type UserWithdrawalData struct {
-	ID          *uuid.UUID `json:"id,omitempty"`
-	UserID      *uuid.UUID `json:"user_id,omitempty"`
-	OrderNumber *string    `json:"order_number,omitempty"`
-	Sum         *float64   `json:"sum,omitempty"`
-	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	ID          *uuid.UUID    `json:"id,omitempty"`
+	UserID      *uuid.UUID    `json:"user_id,omitempty"`
+	OrderNumber *string       `json:"order_number,omitempty"`
+	Sum         *money.Amount `json:"sum,omitempty" swaggertype:"number"`
+	ProcessedAt *time.Time    `json:"processed_at,omitempty"`
+}
+
+type BaseResponseLedger struct {
+	Status bool              `json:"status"`
+	Code   int               `json:"code"`
+	Data   []LedgerEntryData `json:"data,omitempty"`
+}
+
+type LedgerEntryData struct {
+	ID             *uuid.UUID    `json:"id,omitempty"`
+	UserID         *uuid.UUID    `json:"user_id,omitempty"`
+	EntryType      *string       `json:"entry_type,omitempty"`
+	DebitAccount   *string       `json:"debit_account,omitempty"`
+	CreditAccount  *string       `json:"credit_account,omitempty"`
+	Amount         *money.Amount `json:"amount,omitempty" swaggertype:"number"`
+	BalanceAfter   *money.Amount `json:"balance_after" swaggertype:"number"`
+	WithdrawnAfter *money.Amount `json:"withdrawn_after" swaggertype:"number"`
+	OrderNumber    *string       `json:"order_number,omitempty"`
+	ReversalOf     *uuid.UUID    `json:"reversal_of,omitempty"`
+	CreatedAt      *time.Time    `json:"created_at,omitempty"`
 }
 
 type WitdrawalUserBalanceInput struct {
-	OrderNumber *string  `json:"order_number" binding:"required"`
-	Sum         *float64 `json:"sum" binding:"required,gt=0"`
+	OrderNumber *string       `json:"order_number" binding:"required"`
+	Sum         *money.Amount `json:"sum" binding:"required,gt=0" swaggertype:"number"`
 }
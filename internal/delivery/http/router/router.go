@@ -1,13 +1,16 @@
 package router
 
 import (
+	"fmt"
 	"net/http/pprof"
 
+	"github.com/FlyKarlik/gofemart/config"
 	"github.com/FlyKarlik/gofemart/internal/delivery/http/handler"
 	"github.com/FlyKarlik/gofemart/internal/delivery/http/middleware"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
@@ -15,12 +18,14 @@ import (
 )
 
 type HTTPRouter struct {
+	cfg        *config.Config
 	middleware *middleware.Middleware
 	handler    *handler.Handler
 }
 
-func New(middleware *middleware.Middleware, handler *handler.Handler) *HTTPRouter {
+func New(cfg *config.Config, middleware *middleware.Middleware, handler *handler.Handler) *HTTPRouter {
 	return &HTTPRouter{
+		cfg:        cfg,
 		middleware: middleware,
 		handler:    handler,
 	}
@@ -28,8 +33,16 @@ func New(middleware *middleware.Middleware, handler *handler.Handler) *HTTPRoute
 
 func (h *HTTPRouter) InitRouter() *gin.Engine {
 	router := gin.New()
+	// gin trusts every proxy by default, so any client could pick the IP its
+	// login attempts are counted against. The list is validated with the
+	// config, an invalid entry here is a programming error.
+	if err := router.SetTrustedProxies(h.cfg.AppGofemart.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("router: set trusted proxies: %v", err))
+	}
+	router.Use(h.middleware.RequestID)
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
+	router.Use(h.middleware.Metrics)
 	router.Use(cors.New(cors.Config{
 		AllowAllOrigins:  true,
 		AllowCredentials: true,
@@ -39,6 +52,10 @@ func (h *HTTPRouter) InitRouter() *gin.Engine {
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	router.GET("/ping", h.handler.Ping)
+	router.GET("/healthz/live", h.handler.Live)
+	router.GET("/healthz/ready", h.handler.Ready)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/.well-known/jwks.json", h.handler.GetJWKS)
 	registerPprof(router)
 
 	api := router.Group("api", h.middleware.JSONMiddleware())
@@ -54,17 +71,22 @@ func (h *HTTPRouter) registerUserRoutes(router *gin.RouterGroup) {
 	{
 		userGroup.POST("/register", h.handler.RegisterUser)
 		userGroup.POST("/login", h.handler.LoginUser)
+		userGroup.POST("/token/refresh", h.handler.RefreshToken)
+		userGroup.POST("/logout", h.middleware.Identity, h.handler.LogoutUser)
+		userGroup.PUT("/password", h.middleware.Identity, h.handler.ChangePassword)
 
 		ordersGroup := userGroup.Group("orders", h.middleware.Identity)
 		{
-			ordersGroup.POST("/", h.handler.CreateOrder)
+			ordersGroup.POST("/", h.middleware.Idempotency, h.handler.CreateOrder)
 			ordersGroup.GET("/", h.handler.GetUserOrders)
+			ordersGroup.GET("/events", h.handler.StreamOrderEvents)
 		}
 
 		balanceGroup := userGroup.Group("balance", h.middleware.Identity)
 		{
 			balanceGroup.GET("/", h.handler.GetUserBalance)
-			balanceGroup.POST("/withdraw", h.handler.WithdrawUserBalance)
+			balanceGroup.POST("/withdraw", h.middleware.Idempotency, h.handler.WithdrawUserBalance)
+			balanceGroup.GET("/ledger", h.handler.GetUserLedger)
 		}
 
 		withdrawalsGroup := userGroup.Group("withdrawals", h.middleware.Identity)
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/handler"
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func TestInitRouterTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		proxies []string
+		want    string
+	}{
+		{name: "none trusted by default", want: "192.0.2.1"},
+		{name: "trusted proxy", proxies: []string{"192.0.2.0/24"}, want: "203.0.113.7"},
+		{name: "untrusted proxy", proxies: []string{"198.51.100.1"}, want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{AppGofemart: config.AppGofemart{TrustedProxies: tt.proxies}}
+			engine := New(cfg, &middleware.Middleware{}, &handler.Handler{}).InitRouter()
+			engine.GET("/client-ip", func(c *gin.Context) {
+				c.String(http.StatusOK, c.ClientIP())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/client-ip", nil)
+			req.RemoteAddr = "192.0.2.1:40000"
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
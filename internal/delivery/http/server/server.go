@@ -39,6 +39,8 @@ func New(
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	srv.RegisterOnShutdown(handler.CloseStreams)
+
 	httpServer.httpserver = srv
 
 	return httpServer
@@ -48,6 +50,13 @@ func (h *HTTPServer) ListenAndServe() error {
 	return h.httpserver.ListenAndServe()
 }
 
+// Shuttdown stops accepting connections, ends the event streams and waits
+// for the other requests in flight until ctx is done.
 func (h *HTTPServer) Shuttdown(ctx context.Context) error {
 	return h.httpserver.Shutdown(ctx)
 }
+
+// Close drops the connections Shuttdown gave up waiting for.
+func (h *HTTPServer) Close() error {
+	return h.httpserver.Close()
+}
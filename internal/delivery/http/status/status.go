@@ -1,7 +1,9 @@
 package status
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/FlyKarlik/gofemart/internal/errs"
 )
@@ -13,18 +15,36 @@ func HTTPStatusFromError(err error) int {
 			return http.StatusConflict
 		case errs.CodeInvalidLoginOrPassword:
 			return http.StatusUnauthorized
+		case errs.CodeUnauthorized, errs.CodeInvalidToken, errs.CodeInvalidRefreshToken:
+			return http.StatusUnauthorized
 		case errs.CodeUserNotFound:
 			return http.StatusBadRequest
 		case errs.CodeInvalidRequest:
 			return http.StatusBadRequest
+		case errs.CodeRequestTooLarge:
+			return http.StatusRequestEntityTooLarge
 		case errs.CodeOrderByAnotherUserUpload:
 			return http.StatusConflict
+		case errs.CodeOrderAlreadyUpload:
+			return http.StatusOK
+		case errs.CodeInvalidOrderNumber:
+			return http.StatusUnprocessableEntity
 		case errs.CodeNoOrders:
 			return http.StatusNoContent
 		case errs.CodeNotEnoughBalance:
 			return http.StatusPaymentRequired
 		case errs.CodeOrderDoesNotExists:
 			return http.StatusUnprocessableEntity
+		case errs.CodeIdempotencyKeyMismatch:
+			return http.StatusUnprocessableEntity
+		case errs.CodeIdempotencyKeyInProgress:
+			return http.StatusConflict
+		case errs.CodeTooManyLoginAttempts:
+			return http.StatusTooManyRequests
+		case errs.CodeValidationFailed:
+			return http.StatusUnprocessableEntity
+		case errs.CodeInvalidCurrentPassword:
+			return http.StatusForbidden
 		default:
 			return http.StatusInternalServerError
 		}
@@ -33,6 +53,15 @@ func HTTPStatusFromError(err error) int {
 	return http.StatusInternalServerError
 }
 
+// RetryAfterFromError returns the Retry-After header value for err, empty
+// when the error does not carry one.
+func RetryAfterFromError(err error) string {
+	if customErr, ok := err.(*errs.CustomError); ok && customErr.RetryAfter > 0 {
+		return strconv.FormatInt(int64(math.Ceil(customErr.RetryAfter.Seconds())), 10)
+	}
+	return ""
+}
+
 func CodeFromError(err error) errs.CodeEnum {
 	if customErr, ok := err.(*errs.CustomError); ok {
 		return customErr.Code
@@ -1,10 +1,26 @@
 package errs
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type CustomError struct {
 	Code    CodeEnum `json:"code"`
 	Message string   `json:"message"`
+	// RetryAfter tells the client when to try again, zero when it does not
+	// apply.
+	RetryAfter time.Duration `json:"-"`
+	// Fields lists what is wrong with which input field, when the error is
+	// about the request body.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes why one field of the input was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func (c *CustomError) Error() string {
@@ -36,12 +52,54 @@ const (
 	CodeOrderDoesNotExists
 	CodeNotEnoughBalance
 	CodeNooneWithdrawal
+	CodeIdempotencyKeyMismatch
+	CodeIdempotencyKeyInProgress
+	CodeInvalidRefreshToken
+	CodeTooManyLoginAttempts
+	CodeValidationFailed
+	CodeInvalidCurrentPassword
+	CodeRequestTooLarge
 )
 
+var codeNames = map[CodeEnum]string{
+	CodeUnknown:                  "UNKNOWN",
+	CodeLoginInUse:               "LOGIN_IN_USE",
+	CodeInvalidRequest:           "INVALID_REQUEST",
+	CodeUserNotFound:             "USER_NOT_FOUND",
+	CodeInvalidLoginOrPassword:   "INVALID_LOGIN_OR_PASSWORD",
+	CodeUnauthorized:             "UNAUTHORIZED",
+	CodeEmptyAuthHeader:          "EMPTY_AUTH_HEADER",
+	CodeInvalidToken:             "INVALID_TOKEN",
+	CodeOrderByAnotherUserUpload: "ORDER_UPLOADED_BY_ANOTHER_USER",
+	CodeOrderAlreadyUpload:       "ORDER_ALREADY_UPLOADED",
+	CodeNoOrders:                 "NO_ORDERS",
+	CodeInvalidOrderNumber:       "INVALID_ORDER_NUMBER",
+	CodeOrderDoesNotExists:       "ORDER_NOT_FOUND",
+	CodeNotEnoughBalance:         "NOT_ENOUGH_BALANCE",
+	CodeNooneWithdrawal:          "NO_WITHDRAWALS",
+	CodeIdempotencyKeyMismatch:   "IDEMPOTENCY_KEY_MISMATCH",
+	CodeIdempotencyKeyInProgress: "IDEMPOTENCY_KEY_IN_PROGRESS",
+	CodeInvalidRefreshToken:      "INVALID_REFRESH_TOKEN",
+	CodeTooManyLoginAttempts:     "TOO_MANY_LOGIN_ATTEMPTS",
+	CodeValidationFailed:         "VALIDATION_FAILED",
+	CodeInvalidCurrentPassword:   "INVALID_CURRENT_PASSWORD",
+	CodeRequestTooLarge:          "REQUEST_TOO_LARGE",
+}
+
+// String returns the stable machine-readable name of the code. Unlike the
+// numeric value it does not change when codes are added.
+func (c CodeEnum) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return codeNames[CodeUnknown]
+}
+
 var (
 	ErrInvalidToken          = New(CodeInvalidToken, "invalid token")
 	ErrEmptyAuthHeader       = New(CodeEmptyAuthHeader, "empty auth header")
 	ErrInvalidRequest        = New(CodeInvalidRequest, "invalid request")
+	ErrRequestTooLarge       = New(CodeRequestTooLarge, "request body is too large")
 	ErrInvalidLoginOrPassord = New(CodeInvalidLoginOrPassword, "invalid login or password")
 	ErrUnauthorized          = New(CodeUnauthorized, "unauthorized")
 	ErrOrderAlreadyUpload    = New(CodeOrderAlreadyUpload, "your ordder already upload")
@@ -50,4 +108,27 @@ var (
 	ErrOrderDoesNotExists    = New(CodeOrderDoesNotExists, "order does not exists")
 	ErrNotEnoughBalance      = New(CodeNotEnoughBalance, "not enough balance")
 	ErrNooneWithdrawal       = New(CodeNooneWithdrawal, "no one withdrawal")
+
+	ErrIdempotencyKeyMismatch   = New(CodeIdempotencyKeyMismatch, "idempotency key already used with another request")
+	ErrIdempotencyKeyInProgress = New(CodeIdempotencyKeyInProgress, "request with this idempotency key is in progress")
+
+	ErrInvalidRefreshToken = New(CodeInvalidRefreshToken, "invalid refresh token")
+
+	ErrInvalidCurrentPassword = New(CodeInvalidCurrentPassword, "invalid current password")
 )
+
+func NewTooManyLoginAttempts(retryAfter time.Duration) *CustomError {
+	return &CustomError{
+		Code:       CodeTooManyLoginAttempts,
+		Message:    "too many login attempts",
+		RetryAfter: retryAfter,
+	}
+}
+
+func NewValidationFailed(fields ...FieldError) *CustomError {
+	return &CustomError{
+		Code:    CodeValidationFailed,
+		Message: "validation failed",
+		Fields:  fields,
+	}
+}
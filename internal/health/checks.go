@@ -0,0 +1,45 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IOrderBacklogSource interface {
+	GetOrderBacklog(ctx context.Context) (*model.OrderBacklog, error)
+}
+
+func PingPostgres(pool *pgxpool.Pool) CheckFunc {
+	return pool.Ping
+}
+
+func PingRedis(client *redis.Client) CheckFunc {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// AccrualBacklog fails when the oldest order still waiting for its accrual
+// was uploaded more than maxAge ago, i.e. the worker is not keeping up.
+func AccrualBacklog(source IOrderBacklogSource, maxAge time.Duration) CheckFunc {
+	return func(ctx context.Context) error {
+		backlog, err := source.GetOrderBacklog(ctx)
+		if err != nil {
+			return err
+		}
+
+		if backlog.OldestUploadedAt == nil {
+			return nil
+		}
+
+		if age := time.Since(*backlog.OldestUploadedAt); age > maxAge {
+			return fmt.Errorf("%d orders pending, oldest waiting for %s", backlog.Pending, age.Truncate(time.Second))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp       Status = "up"
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+const (
+	CheckPostgres       = "postgres"
+	CheckRedis          = "redis"
+	CheckAccrualBacklog = "accrual_backlog"
+)
+
+// CheckFunc reports a dependency as healthy by returning nil.
+type CheckFunc func(ctx context.Context) error
+
+type CheckResult struct {
+	Status    Status  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status       Status                 `json:"status"`
+	ShuttingDown bool                   `json:"shutting_down,omitempty"`
+	Checks       map[string]CheckResult `json:"checks"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs the registered dependency checks for the readiness probe.
+// A failing critical check makes the service not ready, a failing
+// non-critical one only degrades it.
+type Checker struct {
+	timeout      time.Duration
+	critical     map[string]bool
+	checks       []check
+	shuttingDown atomic.Bool
+}
+
+func New(timeout time.Duration, critical []string) *Checker {
+	criticalSet := make(map[string]bool, len(critical))
+	for _, name := range critical {
+		criticalSet[name] = true
+	}
+
+	return &Checker{
+		timeout:  timeout,
+		critical: criticalSet,
+	}
+}
+
+// Register adds a check. It is not safe to call once the server is serving.
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// SetShuttingDown makes every following readiness report fail so that load
+// balancers stop routing to the instance while it drains.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Ready runs all checks concurrently, each bounded by the checker timeout.
+func (c *Checker) Ready(ctx context.Context) Report {
+	results := make([]CheckResult, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, ch)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(c.checks)),
+	}
+	for i, ch := range c.checks {
+		result := results[i]
+		report.Checks[ch.name] = result
+
+		switch {
+		case result.Status == StatusUp:
+		case result.Critical:
+			report.Status = StatusDown
+		case report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+
+	if c.shuttingDown.Load() {
+		report.Status = StatusDown
+		report.ShuttingDown = true
+	}
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, ch check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ch.fn(ctx)
+
+	result := CheckResult{
+		Status:    StatusUp,
+		Critical:  c.critical[ch.name],
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
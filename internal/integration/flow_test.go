@@ -0,0 +1,143 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/pgtest"
+	"github.com/FlyKarlik/gofemart/pkg/accrual"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+	"github.com/google/uuid"
+)
+
+const testPassword = "correct-horse-42"
+
+func TestUserFlow(t *testing.T) {
+	app := newTestApp(t)
+	client := app.client(t)
+	other := app.client(t)
+
+	credentials := map[string]string{"login": "flow-" + uuid.NewString(), "password": testPassword}
+	otherCredentials := map[string]string{"login": "flow-" + uuid.NewString(), "password": testPassword}
+
+	status, body := client.postJSON("/api/user/register", credentials)
+	client.expect(status, body, http.StatusOK, "register")
+	status, body = client.postJSON("/api/user/register", credentials)
+	client.expect(status, body, http.StatusConflict, "register a taken login")
+	status, body = other.postJSON("/api/user/register", otherCredentials)
+	other.expect(status, body, http.StatusOK, "register the other user")
+
+	status, body = client.postJSON("/api/user/login", map[string]string{"login": credentials["login"], "password": "wrong-password"})
+	client.expect(status, body, http.StatusUnauthorized, "login with a wrong password")
+
+	status, body = client.get("/api/user/orders/")
+	client.expect(status, body, http.StatusUnauthorized, "list orders without a token")
+
+	client.login(credentials)
+	other.login(otherCredentials)
+
+	status, body = client.get("/api/user/orders/")
+	client.expect(status, body, http.StatusNoContent, "list orders before any upload")
+
+	number := pgtest.OrderNumber()
+	status, body = client.do(http.MethodPost, "/api/user/orders/", "text/plain", []byte(number))
+	client.expect(status, body, http.StatusAccepted, "upload an order")
+	status, body = client.do(http.MethodPost, "/api/user/orders/", "text/plain", []byte(number))
+	client.expect(status, body, http.StatusOK, "upload the order again")
+	status, body = other.do(http.MethodPost, "/api/user/orders/", "text/plain", []byte(number))
+	other.expect(status, body, http.StatusConflict, "upload another user's order")
+	status, body = client.do(http.MethodPost, "/api/user/orders/", "text/plain", []byte("12345678901"))
+	client.expect(status, body, http.StatusUnprocessableEntity, "upload an invalid order number")
+
+	app.accrual.SetOrder(number, accrual.OrderStatusProcessed, 729.98)
+	order := client.waitOrderStatus(number, model.OrderStatusEnumProcessed)
+	if order.Accrual == nil || order.Accrual.Minor() != 72998 {
+		t.Fatalf("order accrual = %v, want 729.98", order.Accrual)
+	}
+
+	client.expectBalance(72998, 0)
+
+	status, body = client.postJSON("/api/user/balance/withdraw", map[string]any{"order_number": number, "sum": 100})
+	client.expect(status, body, http.StatusOK, "withdraw")
+	status, body = client.postJSON("/api/user/balance/withdraw", map[string]any{"order_number": number, "sum": 1000})
+	client.expect(status, body, http.StatusPaymentRequired, "withdraw more than the balance")
+
+	client.expectBalance(62998, 10000)
+
+	status, body = client.get("/api/user/withdrawals/")
+	client.expect(status, body, http.StatusOK, "list withdrawals")
+	withdrawals := decodeData[[]model.UserWithdrawal](t, body)
+	if len(withdrawals) != 1 {
+		t.Fatalf("withdrawals = %d, want 1", len(withdrawals))
+	}
+	if got := withdrawals[0]; *got.OrderNumber != number || got.Sum.Minor() != 10000 {
+		t.Errorf("withdrawal = %s %v, want %s 100", *got.OrderNumber, got.Sum, number)
+	}
+
+	status, body = client.get("/api/user/balance/ledger")
+	client.expect(status, body, http.StatusOK, "get ledger")
+	ledger := decodeData[[]model.LedgerEntry](t, body)
+	if len(ledger) != 2 {
+		t.Fatalf("ledger entries = %d, want 2", len(ledger))
+	}
+	if last := ledger[len(ledger)-1]; last.BalanceAfter.Minor() != 62998 || last.WithdrawnAfter.Minor() != 10000 {
+		t.Errorf("ledger ends at %v / %v, want 629.98 / 100", last.BalanceAfter, last.WithdrawnAfter)
+	}
+
+	status, body = other.get("/api/user/withdrawals/")
+	other.expect(status, body, http.StatusNoContent, "list withdrawals of the other user")
+}
+
+func (c *testClient) login(credentials map[string]string) {
+	c.t.Helper()
+
+	status, body := c.postJSON("/api/user/login", credentials)
+	c.expect(status, body, http.StatusOK, "login")
+	tokens := decodeData[model.TokenPair](c.t, body)
+	if tokens.AccessToken == "" {
+		c.t.Fatalf("login returned no access token: %s", body)
+	}
+	c.token = tokens.AccessToken
+}
+
+// waitOrderStatus polls the order list until the accrual worker has moved
+// number to want.
+func (c *testClient) waitOrderStatus(number string, want model.OrderStatusEnum) model.UserOrder {
+	c.t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, body := c.get("/api/user/orders/")
+		c.expect(status, body, http.StatusOK, "list orders")
+		for _, order := range decodeData[[]model.UserOrder](c.t, body) {
+			if *order.Number == number && *order.Status == want {
+				return order
+			}
+		}
+		if time.Now().After(deadline) {
+			c.t.Fatalf("order %s did not reach %s, last listing: %s", number, want, body)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (c *testClient) expectBalance(current int64, withdrawn int64) {
+	c.t.Helper()
+
+	status, body := c.get("/api/user/balance/")
+	c.expect(status, body, http.StatusOK, "get balance")
+	balance := decodeData[model.UserBalance](c.t, body)
+	if minor(balance.Current) != current || minor(balance.Withdrawn) != withdrawn {
+		c.t.Fatalf("balance = %s, want current %s, withdrawn %s", body, money.FromMinor(current), money.FromMinor(withdrawn))
+	}
+}
+
+// minor reads an amount that is left out of the response when it is zero.
+func minor(amount *money.Amount) int64 {
+	if amount == nil {
+		return 0
+	}
+	return amount.Minor()
+}
@@ -0,0 +1,176 @@
+// Package integration runs the HTTP API end to end: the full router on top of
+// the Postgres repositories, migrated by the migrator, and the accrual worker
+// polling the stand-in accrual system. The Redis backed parts are replaced
+// with their in-memory versions. The tests are skipped unless
+// TEST_POSTGRES_DSN is set.
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/accrualmock"
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/handler"
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/middleware"
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/response"
+	"github.com/FlyKarlik/gofemart/internal/delivery/http/router"
+	"github.com/FlyKarlik/gofemart/internal/health"
+	"github.com/FlyKarlik/gofemart/internal/pgtest"
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/internal/repository/memory"
+	"github.com/FlyKarlik/gofemart/internal/repository/postgres"
+	"github.com/FlyKarlik/gofemart/internal/usecase"
+	"github.com/FlyKarlik/gofemart/internal/worker"
+	"github.com/FlyKarlik/gofemart/pkg/accrual"
+	"github.com/FlyKarlik/gofemart/pkg/jwt"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/gin-gonic/gin"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type testApp struct {
+	url     string
+	accrual *accrualmock.Server
+}
+
+// newTestApp migrates the test database and serves the API on top of it.
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	pool := pgtest.Pool(t)
+	cfg := pgtest.Config(t, pgtest.DSN(t))
+
+	accrualServer, accrualMock := accrualmock.NewHTTPTestServer(accrualmock.Config{})
+	t.Cleanup(accrualServer.Close)
+
+	cfg.AppGofemart.AccrualSystemAddress = accrualServer.URL
+	cfg.AppGofemart.AccrualPollInterval = 20 * time.Millisecond
+	cfg.AppGofemart.AccrualBackoffBase = 20 * time.Millisecond
+	cfg.AppGofemart.AccrualBackoffMax = 200 * time.Millisecond
+	cfg.AppGofemart.JWTIssuer = "https://gofemart.test"
+	cfg.AppGofemart.JWTTokenTTL = time.Hour
+	cfg.AppGofemart.PasswordHashAlgorithm = "bcrypt"
+	cfg.AppGofemart.PasswordBcryptCost = 4
+
+	log, err := logger.New(cfg.AppGofemart.LogLevel)
+	if err != nil {
+		t.Fatalf("create logger: %v", err)
+	}
+	keys, err := jwt.LoadKeySet(jwt.KeySetConfig{HMACSecret: "test-secret"})
+	if err != nil {
+		t.Fatalf("load jwt keys: %v", err)
+	}
+
+	caches := memory.New()
+	txManager := postgres.NewTxManager(log, pool, pgx.ReadCommitted, cfg.Infra.Postgres.TxRetryAttempts)
+	repo := &repository.Repository{
+		ITxManager:         txManager,
+		IUserRepository:    postgres.NewUserRepo(log, pool, txManager),
+		ITokenRepository:   postgres.NewTokenRepo(log, pool, txManager),
+		IUserCache:         caches.IUserCache,
+		IBalanceCache:      caches.IBalanceCache,
+		IIdempotencyCache:  caches.IIdempotencyCache,
+		ITokenDenylist:     caches.ITokenDenylist,
+		ILoginAttemptCache: caches.ILoginAttemptCache,
+		IOrderEventBus:     caches.IOrderEventBus,
+	}
+	uc := usecase.New(cfg, log, repo, accrual.New(cfg.AppGofemart.AccrualSystemAddress), keys)
+
+	httpHandler := handler.New(log, uc, health.New(cfg.AppGofemart.HealthCheckTimeout, nil))
+	httpMiddleware := middleware.New(cfg, log, uc)
+	server := httptest.NewServer(router.New(cfg, httpMiddleware, httpHandler).InitRouter())
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		worker.NewAccrualWorker(cfg, log, uc).Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-workerDone
+	})
+
+	return &testApp{url: server.URL, accrual: accrualMock}
+}
+
+type testClient struct {
+	t     *testing.T
+	url   string
+	token string
+}
+
+func (a *testApp) client(t *testing.T) *testClient {
+	return &testClient{t: t, url: a.url}
+}
+
+// do sends the request and returns the status code and the body.
+func (c *testClient) do(method string, path string, contentType string, body []byte) (int, []byte) {
+	c.t.Helper()
+
+	req, err := http.NewRequest(method, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		c.t.Fatalf("build %s %s: %v", method, path, err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatalf("read %s %s: %v", method, path, err)
+	}
+	return resp.StatusCode, data
+}
+
+func (c *testClient) postJSON(path string, input any) (int, []byte) {
+	c.t.Helper()
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		c.t.Fatalf("encode %s body: %v", path, err)
+	}
+	return c.do(http.MethodPost, path, "application/json", body)
+}
+
+func (c *testClient) get(path string) (int, []byte) {
+	c.t.Helper()
+	return c.do(http.MethodGet, path, "", nil)
+}
+
+// expect fails the test unless the response has the wanted status.
+func (c *testClient) expect(status int, body []byte, want int, what string) {
+	c.t.Helper()
+
+	if status != want {
+		c.t.Fatalf("%s: status = %d, want %d, body: %s", what, status, want, body)
+	}
+}
+
+func decodeData[T any](t *testing.T, body []byte) T {
+	t.Helper()
+
+	var resp response.BaseResponse[T]
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode response %s: %v", body, err)
+	}
+	return resp.Data
+}
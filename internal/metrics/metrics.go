@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "gofemart"
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache lookups by cache and result: hit, stale, miss or error.",
+	}, []string{"cache", "result"})
+
+	OrdersUploaded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "orders",
+		Name:      "uploaded_total",
+		Help:      "Orders accepted for accrual.",
+	})
+
+	OrdersProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "orders",
+		Name:      "status_changes_total",
+		Help:      "Order status changes applied from the accrual system.",
+	}, []string{"status"})
+
+	PointsAccrued = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "balance",
+		Name:      "points_accrued_total",
+		Help:      "Points credited to users for processed orders.",
+	})
+
+	Withdrawals = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "balance",
+		Name:      "withdrawals_total",
+		Help:      "Successful withdrawals.",
+	})
+
+	PointsWithdrawn = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "balance",
+		Name:      "points_withdrawn_total",
+		Help:      "Points spent by users on withdrawals.",
+	})
+)
+
+const (
+	CacheResultHit   = "hit"
+	CacheResultStale = "stale"
+	CacheResultMiss  = "miss"
+	CacheResultError = "error"
+)
@@ -3,20 +3,33 @@ package model
 type EventTypeEnum string
 
 const (
-	EventTypeEnumRegisterUser        EventTypeEnum = "REGISTER_USER"
-	EventTypeEnumLoginUser           EventTypeEnum = "LOGIN_USER"
-	EventTypeEnumGetUserByID         EventTypeEnum = "GET_USER_BY_ID"
-	EventTypeEnumCreateOrder         EventTypeEnum = "CREATE_ORDER"
-	EventTypeEnumGetUserOrders       EventTypeEnum = "GET_USER_ORDERS"
-	EventTypeEnumGetUserBalance      EventTypeEnum = "GET_USER_BALANCE"
-	EventTypeEnumWithdrawUserBalance EventTypeEnum = "WITHDRAW_USER_BALANCE"
-	EventTypeEnumGetUserWithdrawals  EventTypeEnum = "GET_USER_WITHDRAWALS"
+	EventTypeEnumRegisterUser         EventTypeEnum = "REGISTER_USER"
+	EventTypeEnumLoginUser            EventTypeEnum = "LOGIN_USER"
+	EventTypeEnumChangePassword       EventTypeEnum = "CHANGE_PASSWORD"
+	EventTypeEnumGetUserByID          EventTypeEnum = "GET_USER_BY_ID"
+	EventTypeEnumCreateOrder          EventTypeEnum = "CREATE_ORDER"
+	EventTypeEnumGetUserOrders        EventTypeEnum = "GET_USER_ORDERS"
+	EventTypeEnumGetUserBalance       EventTypeEnum = "GET_USER_BALANCE"
+	EventTypeEnumWithdrawUserBalance  EventTypeEnum = "WITHDRAW_USER_BALANCE"
+	EventTypeEnumGetUserWithdrawals   EventTypeEnum = "GET_USER_WITHDRAWALS"
+	EventTypeEnumGetPendingOrders     EventTypeEnum = "GET_PENDING_ORDERS"
+	EventTypeEnumGetOrderBacklog      EventTypeEnum = "GET_ORDER_BACKLOG"
+	EventTypeEnumProcessOrderAccrual  EventTypeEnum = "PROCESS_ORDER_ACCRUAL"
+	EventTypeEnumIdempotency          EventTypeEnum = "IDEMPOTENCY"
+	EventTypeEnumGetUserLedger        EventTypeEnum = "GET_USER_LEDGER"
+	EventTypeEnumRefreshToken         EventTypeEnum = "REFRESH_TOKEN"
+	EventTypeEnumLogoutUser           EventTypeEnum = "LOGOUT_USER"
+	EventTypeEnumCheckAccessToken     EventTypeEnum = "CHECK_ACCESS_TOKEN"
+	EventTypeEnumSubscribeOrderEvents EventTypeEnum = "SUBSCRIBE_ORDER_EVENTS"
 )
 
 type ContextKeyEnum string
 
 const (
-	ContextKeyEnumUserID ContextKeyEnum = "USER"
+	ContextKeyEnumUserID         ContextKeyEnum = "USER"
+	ContextKeyEnumTokenID        ContextKeyEnum = "TOKEN_ID"
+	ContextKeyEnumTokenExpiresAt ContextKeyEnum = "TOKEN_EXPIRES_AT"
+	ContextKeyEnumRequestID      ContextKeyEnum = "REQUEST_ID"
 )
 
 func (c ContextKeyEnum) String() string {
@@ -35,3 +48,40 @@ const (
 func (c OrderStatusEnum) String() string {
 	return string(c)
 }
+
+type LedgerEntryTypeEnum string
+
+const (
+	LedgerEntryTypeEnumAccrual    LedgerEntryTypeEnum = "ACCRUAL"
+	LedgerEntryTypeEnumWithdrawal LedgerEntryTypeEnum = "WITHDRAWAL"
+	LedgerEntryTypeEnumAdjustment LedgerEntryTypeEnum = "ADJUSTMENT"
+	LedgerEntryTypeEnumReversal   LedgerEntryTypeEnum = "REVERSAL"
+)
+
+func (c LedgerEntryTypeEnum) String() string {
+	return string(c)
+}
+
+type LedgerAccountEnum string
+
+const (
+	LedgerAccountEnumUser       LedgerAccountEnum = "USER"
+	LedgerAccountEnumAccrual    LedgerAccountEnum = "ACCRUAL"
+	LedgerAccountEnumWithdrawal LedgerAccountEnum = "WITHDRAWAL"
+	LedgerAccountEnumAdjustment LedgerAccountEnum = "ADJUSTMENT"
+)
+
+func (c LedgerAccountEnum) String() string {
+	return string(c)
+}
+
+type SortDirectionEnum string
+
+const (
+	SortDirectionEnumAsc  SortDirectionEnum = "asc"
+	SortDirectionEnumDesc SortDirectionEnum = "desc"
+)
+
+func (c SortDirectionEnum) String() string {
+	return string(c)
+}
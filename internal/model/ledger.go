@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/FlyKarlik/gofemart/pkg/money"
+	"github.com/google/uuid"
+)
+
+type LedgerEntry struct {
+	ID             *uuid.UUID           `json:"id,omitempty"`
+	UserID         *uuid.UUID           `json:"user_id,omitempty"`
+	EntryType      *LedgerEntryTypeEnum `json:"entry_type,omitempty"`
+	DebitAccount   *LedgerAccountEnum   `json:"debit_account,omitempty"`
+	CreditAccount  *LedgerAccountEnum   `json:"credit_account,omitempty"`
+	Amount         *money.Amount        `json:"amount,omitempty"`
+	BalanceAfter   *money.Amount        `json:"balance_after"`
+	WithdrawnAfter *money.Amount        `json:"withdrawn_after"`
+	OrderNumber    *string              `json:"order_number,omitempty"`
+	ReversalOf     *uuid.UUID           `json:"reversal_of,omitempty"`
+	CreatedAt      *time.Time           `json:"created_at,omitempty"`
+}
+
+type LedgerEntryInput struct {
+	UserID        *uuid.UUID
+	EntryType     *LedgerEntryTypeEnum
+	DebitAccount  *LedgerAccountEnum
+	CreditAccount *LedgerAccountEnum
+	Amount        *money.Amount
+	OrderNumber   *string
+	ReversalOf    *uuid.UUID
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// OrderBacklog summarises orders still waiting for their accrual.
+type OrderBacklog struct {
+	Pending          int64      `json:"pending"`
+	OldestUploadedAt *time.Time `json:"oldest_uploaded_at,omitempty"`
+}
+
+// OrderPollBackoff spaces the polls of an order that stays pending: the
+// next one is due Base after the first, doubling up to Max.
+type OrderPollBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns how long to wait before polling again an order that was
+// already polled attempts times.
+func (b OrderPollBackoff) Delay(attempts int) time.Duration {
+	delay := b.Base
+	for i := 0; i < attempts && delay < b.Max; i++ {
+		delay *= 2
+	}
+	return min(delay, b.Max)
+}
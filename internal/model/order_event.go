@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/FlyKarlik/gofemart/pkg/money"
+	"github.com/google/uuid"
+)
+
+// OrderEvent reports the new state of an order after its status or accrual
+// changed.
+type OrderEvent struct {
+	UserID    *uuid.UUID       `json:"-"`
+	Number    *string          `json:"number"`
+	Status    *OrderStatusEnum `json:"status"`
+	Accrual   *money.Amount    `json:"accrual,omitempty" swaggertype:"number"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
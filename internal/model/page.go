@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ListQuery holds the listing parameters as they come from the query string.
+type ListQuery struct {
+	Limit  uint64            `form:"limit" binding:"omitempty,gte=1,lte=1000"`
+	Cursor string            `form:"cursor"`
+	From   *time.Time        `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     *time.Time        `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Sort   SortDirectionEnum `form:"sort" binding:"omitempty,oneof=asc desc"`
+}
+
+type OrderListQuery struct {
+	ListQuery
+	Status []OrderStatusEnum `form:"status" binding:"omitempty,dive,oneof=NEW PROCESSING INVALID PROCESSED"`
+}
+
+// PageCursor points at the last row of the previous page. Rows are ordered
+// by time and id, so the pair is unique even for equal timestamps.
+type PageCursor struct {
+	Time time.Time         `json:"t"`
+	ID   uuid.UUID         `json:"id"`
+	Sort SortDirectionEnum `json:"s"`
+}
+
+// ListFilter is the repository side of ListQuery. Limit is the exact number
+// of rows to fetch.
+type ListFilter struct {
+	Limit uint64
+	After *PageCursor
+	From  *time.Time
+	To    *time.Time
+	Sort  SortDirectionEnum
+}
+
+type OrderListFilter struct {
+	ListFilter
+	Statuses []OrderStatusEnum
+}
+
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken *string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutInput struct {
+	RefreshToken *string `json:"refresh_token"`
+}
+
+type RefreshToken struct {
+	ID        *uuid.UUID
+	UserID    *uuid.UUID
+	FamilyID  *uuid.UUID
+	TokenHash *string
+	ExpiresAt *time.Time
+	CreatedAt *time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+type CreateRefreshTokenInput struct {
+	UserID    *uuid.UUID
+	FamilyID  *uuid.UUID
+	TokenHash *string
+	ExpiresAt *time.Time
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"time"
 
+	"github.com/FlyKarlik/gofemart/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -18,12 +19,17 @@ type UserInput struct {
 	Password *string `json:"password" binding:"required"`
 }
 
+type ChangePasswordInput struct {
+	OldPassword *string `json:"old_password" binding:"required"`
+	NewPassword *string `json:"new_password" binding:"required"`
+}
+
 type UserOrder struct {
 	ID         *uuid.UUID       `json:"id,omitempty"`
 	UserID     *uuid.UUID       `json:"user_id,omitempty"`
 	Number     *string          `json:"number,omitempty"`
 	Status     *OrderStatusEnum `json:"status,omitempty"`
-	Accrual    *int64           `json:"accural,omitempty"`
+	Accrual    *money.Amount    `json:"accural,omitempty" swaggertype:"number"`
 	UploadedAt *time.Time       `json:"uploaded_at,omitempty"`
 }
 
@@ -33,24 +39,28 @@ type UserOrderInput struct {
 	Status *OrderStatusEnum
 }
 
-type UserBalance[T int64 | float64] struct {
-	UserID    *uuid.UUID `json:"user_id,omitempty"`
-	Current   *T         `json:"current,omitempty"`
-	Withdrawn *T         `json:"withdrawn,omitempty"`
+type UserOrderAccrualInput struct {
+	Number  *string
+	Status  *OrderStatusEnum
+	Accrual *money.Amount
+}
+
+type UserBalance struct {
+	UserID    *uuid.UUID    `json:"user_id,omitempty"`
+	Current   *money.Amount `json:"current,omitempty"`
+	Withdrawn *money.Amount `json:"withdrawn,omitempty"`
 }
 
-type UserWithdrawal[T int64 | float64] struct {
-	ID          *uuid.UUID `json:"id,omitempty"`
-	UserID      *uuid.UUID `json:"user_id,omitempty"`
-	OrderNumber *string    `json:"order_number,omitempty"`
-	Sum         *T         `json:"sum,omitempty"`
-	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+type UserWithdrawal struct {
+	ID          *uuid.UUID    `json:"id,omitempty"`
+	UserID      *uuid.UUID    `json:"user_id,omitempty"`
+	OrderNumber *string       `json:"order_number,omitempty"`
+	Sum         *money.Amount `json:"sum,omitempty"`
+	ProcessedAt *time.Time    `json:"processed_at,omitempty"`
 }
 
-type UserWithdrawalInput[T int64 | float64] struct {
-	UserID          *uuid.UUID
-	WitdrawnBalance *T
-	CurrentBalance  *T
-	OrderNumber     *string `json:"order_number" binding:"required"`
-	Sum             *T      `json:"sum" binding:"required,gt=0"`
+type UserWithdrawalInput struct {
+	UserID      *uuid.UUID
+	OrderNumber *string       `json:"order_number" binding:"required"`
+	Sum         *money.Amount `json:"sum" binding:"required,gt=0"`
 }
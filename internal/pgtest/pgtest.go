@@ -0,0 +1,150 @@
+// Package pgtest prepares a PostgreSQL database for tests that need a real
+// one. Tests using it are skipped unless TEST_POSTGRES_DSN is set.
+package pgtest
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/app/migrator"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DSNEnv names the environment variable holding the connection string of
+// the database tests may migrate and write to.
+const DSNEnv = "TEST_POSTGRES_DSN"
+
+// extensionLockID serializes the extension setup of test binaries run in
+// parallel against the same database.
+const extensionLockID = 4242
+
+// DSN returns the connection string of the test database and skips t when
+// none is configured.
+func DSN(t testing.TB) string {
+	t.Helper()
+
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", DSNEnv)
+	}
+	return dsn
+}
+
+// Config returns the default configuration with the application and the
+// migrator pointed at the test database.
+func Config(t testing.TB, dsn string) *config.Config {
+	t.Helper()
+
+	cfg, err := config.New("")
+	if err != nil {
+		t.Fatalf("read config defaults: %v", err)
+	}
+	cfg.AppGofemart.LogLevel = "error"
+	cfg.AppMigrator.LogLevel = "error"
+	cfg.AppMigrator.MigrationsPath = MigrationsPath()
+	cfg.Infra.Postgres.ConnStr = dsn
+	return cfg
+}
+
+// MigrationsPath returns the absolute path of the repository migrations.
+func MigrationsPath() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+}
+
+// Migrate applies the repository migrations to the database at dsn through
+// the migrator.
+func Migrate(t testing.TB, dsn string) {
+	t.Helper()
+
+	pool := connect(t, dsn)
+	defer pool.Close()
+
+	// The migrations call uuid_generate_v4 and expect the extension to be
+	// installed with the database.
+	ctx := context.Background()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin extension setup: %v", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, extensionLockID); err != nil {
+		t.Fatalf("lock extension setup: %v", err)
+	}
+	if _, err := tx.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`); err != nil {
+		t.Fatalf("create uuid-ossp extension: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit extension setup: %v", err)
+	}
+
+	cfg := Config(t, dsn)
+	log, err := logger.New(cfg.AppMigrator.LogLevel)
+	if err != nil {
+		t.Fatalf("create logger: %v", err)
+	}
+	if err := migrator.New(cfg, log).Migrate("up"); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+}
+
+// Pool migrates the test database and returns a pool connected to it, which
+// is closed when t finishes.
+func Pool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := DSN(t)
+	Migrate(t, dsn)
+
+	pool := connect(t, dsn)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// OrderNumber returns a random order number that passes the Luhn check, so
+// tests sharing a database do not collide.
+func OrderNumber() string {
+	digits := make([]byte, 15, 16)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			panic(err)
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+
+	var sum int
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return string(append(digits, byte('0'+(10-sum%10)%10)))
+}
+
+func connect(t testing.TB, dsn string) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		t.Fatalf("ping test database: %v", err)
+	}
+	return pool
+}
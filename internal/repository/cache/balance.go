@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	balanceCacheName = "balance"
+
+	// balanceSchemaVersion must be bumped whenever model.UserBalance changes
+	// shape.
+	balanceSchemaVersion = 1
+
+	// balanceGenerationTTL keeps generations far longer than any balance is
+	// cached, so a generation never restarts under a live balance.
+	balanceGenerationTTL = 24 * time.Hour
+)
+
+type balanceItem struct {
+	Generation int64
+	Balance    model.UserBalance
+}
+
+type BalanceCacheConfig struct {
+	Namespace        string
+	Codec            Codec
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// BalanceCache keeps user balances in Redis. Every balance is stored with
+// the generation of the user it was read at and Invalidate moves the
+// generation on, so a balance read from the database while a write was
+// committing is never served after that write.
+type BalanceCache struct {
+	logger      logger.Logger
+	client      *redis.Client
+	values      *Typed[uuid.UUID, balanceItem]
+	generations Keyspace
+}
+
+func NewBalanceCache(logger logger.Logger, client *redis.Client, cfg BalanceCacheConfig) *BalanceCache {
+	c := &BalanceCache{
+		logger:      logger,
+		client:      client,
+		generations: Keyspace{Namespace: cfg.Namespace, Entity: balanceCacheName + "_generation", Version: balanceSchemaVersion},
+	}
+	breaker := newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown, func(from breakerState, to breakerState) {
+		c.logger.Warn("cache[balance]", "breaker", "Redis circuit breaker changed state", nil, from.String(), to.String())
+	})
+	c.values = NewTyped[uuid.UUID, balanceItem](client, TypedOptions[balanceItem]{
+		Keyspace: Keyspace{Namespace: cfg.Namespace, Entity: balanceCacheName, Version: balanceSchemaVersion},
+		Codec:    cfg.Codec,
+		Breaker:  breaker,
+	})
+	return c
+}
+
+// GetOrLoad returns the cached balance of userID or the one load returns,
+// which is then cached for ttl. Errors of the cache itself never reach the
+// caller.
+func (c *BalanceCache) GetOrLoad(ctx context.Context, userID uuid.UUID, ttl time.Duration, load func(ctx context.Context, userID uuid.UUID) (*model.UserBalance, error)) (*model.UserBalance, error) {
+	// The generation is read before the balance is loaded, a write that
+	// commits in between moves it on and voids what is stored below.
+	generation, err := c.generation(ctx, userID)
+	if err != nil {
+		c.warn("GetOrLoad", "Failed to get balance generation", err, userID)
+		return load(ctx, userID)
+	}
+
+	item, found, err := c.values.Get(ctx, userID)
+	if err != nil {
+		c.warn("GetOrLoad", "Failed to get balance from redis", err, userID)
+	}
+	if found && item.Generation == generation {
+		balance := item.Balance
+		return &balance, nil
+	}
+
+	balance, err := load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.values.Set(ctx, userID, balanceItem{Generation: generation, Balance: *balance}, jitter(ttl)); err != nil {
+		c.warn("GetOrLoad", "Failed to set balance to redis", err, userID)
+	}
+	return balance, nil
+}
+
+// Invalidate voids the cached balance of userID. It must be called after the
+// change to the balance is committed.
+func (c *BalanceCache) Invalidate(ctx context.Context, userID uuid.UUID) error {
+	return c.values.call(func() error {
+		_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Incr(ctx, c.generations.key(userID))
+			pipe.Expire(ctx, c.generations.key(userID), balanceGenerationTTL)
+			pipe.Del(ctx, c.values.opts.Keyspace.key(userID))
+			return nil
+		})
+		return err
+	})
+}
+
+func (c *BalanceCache) generation(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var generation int64
+	err := c.values.call(func() error {
+		var err error
+		generation, err = c.client.Get(ctx, c.generations.key(userID)).Int64()
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	})
+	return generation, err
+}
+
+// warn logs err unless it only says that Redis is skipped for now.
+func (c *BalanceCache) warn(method string, msg string, err error, userID uuid.UUID) {
+	if errors.Is(err, errBreakerOpen) {
+		return
+	}
+	c.logger.Warn("cache[balance]", method, msg, err, userID)
+}
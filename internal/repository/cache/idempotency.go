@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	idempotencyCacheName     = "idempotency"
+	idempotencySchemaVersion = 1
+
+	// idempotencyReserveAttempts bounds how often Reserve tries again when
+	// the key it found taken is gone by the time it is read.
+	idempotencyReserveAttempts = 3
+)
+
+var errIdempotencyKeyContended = errors.New("idempotency key kept changing while being reserved")
+
+type IdempotencyCache struct {
+	logger logger.Logger
+	client *redis.Client
+	keys   Keyspace
+}
+
+func NewIdempotencyCache(logger logger.Logger, client *redis.Client, namespace string) *IdempotencyCache {
+	return &IdempotencyCache{
+		logger: logger,
+		client: client,
+		keys:   Keyspace{Namespace: namespace, Entity: idempotencyCacheName, Version: idempotencySchemaVersion},
+	}
+}
+
+// Reserve stores an in-progress record for key unless one already exists.
+// It returns the existing record and false when the key was taken before.
+func (c *IdempotencyCache) Reserve(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*model.IdempotencyRecord, bool, error) {
+	jsonData, err := json.Marshal(model.IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, err
+	}
+
+	for range idempotencyReserveAttempts {
+		reserved, err := c.client.SetNX(ctx, c.keys.key(key), jsonData, ttl).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if reserved {
+			return nil, true, nil
+		}
+
+		val, err := c.client.Get(ctx, c.keys.key(key)).Result()
+		if err == redis.Nil {
+			// Released or expired in between, try to take it again.
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		var record model.IdempotencyRecord
+		if err := json.Unmarshal([]byte(val), &record); err != nil {
+			return nil, false, err
+		}
+
+		return &record, false, nil
+	}
+
+	return nil, false, errIdempotencyKeyContended
+}
+
+func (c *IdempotencyCache) Complete(ctx context.Context, key string, record *model.IdempotencyRecord, ttl time.Duration) error {
+	jsonData, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return c.client.Set(ctx, c.keys.key(key), jsonData, ttl).Err()
+}
+
+func (c *IdempotencyCache) Release(ctx context.Context, key string) error {
+	return c.client.Del(ctx, c.keys.key(key)).Err()
+}
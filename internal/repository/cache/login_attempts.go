@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	loginFailuresKeyPrefix = "login:failures:"
+	loginLockoutKeyPrefix  = "login:lockout:"
+)
+
+type LoginAttemptCache struct {
+	logger logger.Logger
+	client *redis.Client
+}
+
+func NewLoginAttemptCache(logger logger.Logger, client *redis.Client) *LoginAttemptCache {
+	return &LoginAttemptCache{
+		logger: logger,
+		client: client,
+	}
+}
+
+// GetLockout returns how long key stays locked, zero when it is not locked.
+func (c *LoginAttemptCache) GetLockout(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := c.client.PTTL(ctx, loginLockoutKeyPrefix+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (c *LoginAttemptCache) SetLockout(ctx context.Context, key string, d time.Duration) error {
+	return c.client.Set(ctx, loginLockoutKeyPrefix+key, 1, d).Err()
+}
+
+// RegisterFailure counts a failed attempt for key and returns the number of
+// failures seen since the last success. The counter expires window after the
+// latest failure.
+func (c *LoginAttemptCache) RegisterFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	pipe := c.client.TxPipeline()
+	incr := pipe.Incr(ctx, loginFailuresKeyPrefix+key)
+	pipe.PExpire(ctx, loginFailuresKeyPrefix+key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (c *LoginAttemptCache) ResetFailures(ctx context.Context, key string) error {
+	return c.client.Del(ctx, loginFailuresKeyPrefix+key, loginLockoutKeyPrefix+key).Err()
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	freshUntil time.Time
+	staleUntil time.Time
+}
+
+func (e lruEntry[K, V]) fresh(now time.Time) bool {
+	return now.Before(e.freshUntil)
+}
+
+// lru is a size bounded in-process cache evicting the least recently used
+// entry. An entry is fresh until freshUntil, then stale until staleUntil,
+// after which it is gone.
+type lru[K comparable, V any] struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[K]*list.Element
+}
+
+func newLRU[K comparable, V any](size int) *lru[K, V] {
+	return &lru[K, V]{
+		size:  size,
+		order: list.New(),
+		items: make(map[K]*list.Element, size),
+	}
+}
+
+func (l *lru[K, V]) get(key K) (lruEntry[K, V], bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return lruEntry[K, V]{}, false
+	}
+
+	entry := elem.Value.(lruEntry[K, V])
+	if !time.Now().Before(entry.staleUntil) {
+		l.order.Remove(elem)
+		delete(l.items, key)
+		return lruEntry[K, V]{}, false
+	}
+
+	l.order.MoveToFront(elem)
+	return entry, true
+}
+
+func (l *lru[K, V]) set(key K, value V, fresh time.Duration, stale time.Duration) {
+	now := time.Now()
+	entry := lruEntry[K, V]{
+		key:        key,
+		value:      value,
+		freshUntil: now.Add(fresh),
+		staleUntil: now.Add(fresh + stale),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.items[key] = l.order.PushFront(entry)
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(lruEntry[K, V]).key)
+	}
+}
+
+func (l *lru[K, V]) delete(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.order.Remove(elem)
+		delete(l.items, key)
+	}
+}
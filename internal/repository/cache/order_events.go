@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const orderEventsChannelPrefix = "orders:events:"
+
+// OrderEventBus fans order events out to every gofemart replica over Redis
+// pub/sub. Each user has a channel of their own, so a subscriber only
+// receives events of its user.
+type OrderEventBus struct {
+	logger logger.Logger
+	client *redis.Client
+}
+
+func NewOrderEventBus(logger logger.Logger, client *redis.Client) *OrderEventBus {
+	return &OrderEventBus{
+		logger: logger,
+		client: client,
+	}
+}
+
+func (b *OrderEventBus) Publish(ctx context.Context, event model.OrderEvent) error {
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(ctx, orderEventsChannelPrefix+event.UserID.String(), jsonData).Err()
+}
+
+// Subscribe streams events of userID until ctx is done or close is called.
+// The subscription is confirmed before Subscribe returns, so no event
+// published afterwards is missed.
+func (b *OrderEventBus) Subscribe(ctx context.Context, userID uuid.UUID) (<-chan model.OrderEvent, func() error, error) {
+	pubsub := b.client.Subscribe(ctx, orderEventsChannelPrefix+userID.String())
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, nil, err
+	}
+
+	events := make(chan model.OrderEvent)
+	go func() {
+		defer close(events)
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var event model.OrderEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					b.logger.Error("cache[order-events]", "Subscribe", "Failed to decode order event", err)
+					continue
+				}
+				event.UserID = &userID
+
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, pubsub.Close, nil
+}
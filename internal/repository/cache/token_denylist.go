@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	tokenDenylistKeyPrefix     = "jwt:denylist:"
+	userTokenDenylistKeyPrefix = "jwt:denylist:user:"
+)
+
+// TokenDenylist keeps identifiers (jti) of revoked access tokens, and the
+// time before which all access tokens of a user are revoked, until the
+// tokens would have expired anyway.
+type TokenDenylist struct {
+	logger logger.Logger
+	client *redis.Client
+}
+
+func NewTokenDenylist(logger logger.Logger, client *redis.Client) *TokenDenylist {
+	return &TokenDenylist{
+		logger: logger,
+		client: client,
+	}
+}
+
+func (c *TokenDenylist) Add(ctx context.Context, tokenID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return c.client.Set(ctx, tokenDenylistKeyPrefix+tokenID, 1, ttl).Err()
+}
+
+func (c *TokenDenylist) Contains(ctx context.Context, tokenID string) (bool, error) {
+	n, err := c.client.Exists(ctx, tokenDenylistKeyPrefix+tokenID).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RevokeUser revokes every access token of the user issued up to the second
+// of revokedAt.
+func (c *TokenDenylist) RevokeUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time, ttl time.Duration) error {
+	return c.client.Set(ctx, userTokenDenylistKeyPrefix+userID.String(), revokedAt.Unix(), ttl).Err()
+}
+
+// UserRevokedBefore returns the second up to which the access tokens of the
+// user are revoked, zero when none are.
+func (c *TokenDenylist) UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	unix, err := c.client.Get(ctx, userTokenDenylistKeyPrefix+userID.String()).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(unix, 0), nil
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/metrics"
+	"github.com/go-redis/redis/v8"
+)
+
+var errCodecMismatch = errors.New("cached value was written by another codec")
+
+// Keyspace names the Redis keys of one entity as
+// <namespace>:v<version>:<entity>:<key>. Version is the schema version of
+// the cached value; bumping it makes a deploy ignore blobs of the old shape.
+type Keyspace struct {
+	Namespace string
+	Entity    string
+	Version   int
+}
+
+func (k Keyspace) key(id interface{}) string {
+	return fmt.Sprintf("%s:v%d:%s:%v", k.Namespace, k.Version, k.Entity, id)
+}
+
+type TypedOptions[V any] struct {
+	Keyspace Keyspace
+	Codec    Codec
+	// Redact strips what must never be stored, such as password hashes,
+	// from a copy of the value before it is encoded.
+	Redact func(V) V
+	// Breaker, when set, stops calls to Redis while it keeps failing.
+	Breaker *circuitBreaker
+}
+
+// Typed stores values of V in Redis under keys of K.
+type Typed[K comparable, V any] struct {
+	client *redis.Client
+	opts   TypedOptions[V]
+}
+
+func NewTyped[K comparable, V any](client *redis.Client, opts TypedOptions[V]) *Typed[K, V] {
+	return &Typed[K, V]{
+		client: client,
+		opts:   opts,
+	}
+}
+
+func (t *Typed[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	if t.opts.Redact != nil {
+		value = t.opts.Redact(value)
+	}
+
+	data, err := t.opts.Codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return t.call(func() error {
+		return t.client.Set(ctx, t.opts.Keyspace.key(key), append([]byte{t.opts.Codec.ID()}, data...), ttl).Err()
+	})
+}
+
+func (t *Typed[K, V]) Get(ctx context.Context, key K) (V, bool, error) {
+	var value V
+	var data []byte
+	err := t.call(func() error {
+		var err error
+		data, err = t.client.Get(ctx, t.opts.Keyspace.key(key)).Bytes()
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		metrics.CacheRequests.WithLabelValues(t.opts.Keyspace.Entity, metrics.CacheResultError).Inc()
+		return value, false, err
+	}
+	if len(data) == 0 {
+		metrics.CacheRequests.WithLabelValues(t.opts.Keyspace.Entity, metrics.CacheResultMiss).Inc()
+		return value, false, nil
+	}
+
+	if data[0] != t.opts.Codec.ID() {
+		metrics.CacheRequests.WithLabelValues(t.opts.Keyspace.Entity, metrics.CacheResultMiss).Inc()
+		return value, false, errCodecMismatch
+	}
+
+	if err := t.opts.Codec.Unmarshal(data[1:], &value); err != nil {
+		metrics.CacheRequests.WithLabelValues(t.opts.Keyspace.Entity, metrics.CacheResultError).Inc()
+		return value, false, err
+	}
+
+	metrics.CacheRequests.WithLabelValues(t.opts.Keyspace.Entity, metrics.CacheResultHit).Inc()
+	return value, true, nil
+}
+
+func (t *Typed[K, V]) Delete(ctx context.Context, key K) error {
+	return t.call(func() error {
+		return t.client.Del(ctx, t.opts.Keyspace.key(key)).Err()
+	})
+}
+
+// call runs fn through the breaker, if there is one.
+func (t *Typed[K, V]) call(fn func() error) error {
+	if t.opts.Breaker == nil {
+		return fn()
+	}
+
+	if !t.opts.Breaker.allow() {
+		return errBreakerOpen
+	}
+
+	if err := fn(); err != nil {
+		t.opts.Breaker.failure()
+		return err
+	}
+
+	t.opts.Breaker.success()
+	return nil
+}
@@ -2,68 +2,165 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"math/rand/v2"
 	"time"
 
+	"github.com/FlyKarlik/gofemart/internal/metrics"
 	"github.com/FlyKarlik/gofemart/internal/model"
 	"github.com/FlyKarlik/gofemart/pkg/logger"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
 )
 
-type cacheItem struct {
-	User      *model.User `json:"user"`
-	ExpiresAt time.Time   `json:"expires_at"`
+const (
+	userCacheName   = "user"
+	userL1CacheName = "user_l1"
+
+	// userSchemaVersion must be bumped whenever model.User changes shape.
+	userSchemaVersion = 1
+)
+
+type UserCacheConfig struct {
+	Namespace        string
+	Codec            Codec
+	L1Size           int
+	L1TTL            time.Duration
+	StaleTTL         time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
+// UserCache keeps users in an in-process LRU in front of Redis. Misses of
+// both are coalesced per user, Redis is skipped while it keeps failing and
+// a stale L1 entry is served while it is refreshed in the background.
 type UserCache struct {
 	logger logger.Logger
-	client *redis.Client
+	cfg    UserCacheConfig
+	l1     *lru[uuid.UUID, model.User]
+	remote *Typed[uuid.UUID, model.User]
+	group  singleflight.Group
 }
 
-func NewUserCache(logger logger.Logger, client *redis.Client) *UserCache {
-	return &UserCache{
+func NewUserCache(logger logger.Logger, client *redis.Client, cfg UserCacheConfig) *UserCache {
+	c := &UserCache{
 		logger: logger,
-		client: client,
+		cfg:    cfg,
+		l1:     newLRU[uuid.UUID, model.User](cfg.L1Size),
 	}
+	breaker := newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown, func(from breakerState, to breakerState) {
+		c.logger.Warn("cache[user]", "breaker", "Redis circuit breaker changed state", nil, from.String(), to.String())
+	})
+	c.remote = NewTyped[uuid.UUID, model.User](client, TypedOptions[model.User]{
+		Keyspace: Keyspace{Namespace: cfg.Namespace, Entity: userCacheName, Version: userSchemaVersion},
+		Codec:    cfg.Codec,
+		Redact:   redactUser,
+		Breaker:  breaker,
+	})
+	return c
 }
 
 func (c *UserCache) Set(ctx context.Context, userID uuid.UUID, user *model.User, ttl time.Duration) error {
-	item := cacheItem{
-		User:      user,
-		ExpiresAt: time.Now().Add(ttl),
-	}
+	// Both tiers hold the same redacted user, whichever one answers.
+	c.l1.set(userID, redactUser(*user), jitter(c.cfg.L1TTL), c.cfg.StaleTTL)
 
-	jsonData, err := json.Marshal(item)
-	if err != nil {
-		return err
+	return c.remote.Set(ctx, userID, *user, jitter(ttl))
+}
+
+func (c *UserCache) Get(ctx context.Context, userID uuid.UUID) (*model.User, bool, error) {
+	if entry, ok := c.l1.get(userID); ok && entry.fresh(time.Now()) {
+		metrics.CacheRequests.WithLabelValues(userL1CacheName, metrics.CacheResultHit).Inc()
+		user := entry.value
+		return &user, true, nil
 	}
+	metrics.CacheRequests.WithLabelValues(userL1CacheName, metrics.CacheResultMiss).Inc()
 
-	return c.client.Set(ctx, userID.String(), jsonData, ttl).Err()
+	return c.getRemote(ctx, userID)
 }
 
-func (c *UserCache) Get(ctx context.Context, userID uuid.UUID) (*model.User, bool, error) {
-	val, err := c.client.Get(ctx, userID.String()).Result()
-	if err == redis.Nil {
-		return nil, false, nil
+// GetOrLoad returns the user from the cache or from load, storing what load
+// returns for ttl. Errors of the cache itself never reach the caller.
+func (c *UserCache) GetOrLoad(ctx context.Context, userID uuid.UUID, ttl time.Duration, load func(ctx context.Context, userID uuid.UUID) (*model.User, error)) (*model.User, error) {
+	if entry, ok := c.l1.get(userID); ok {
+		user := entry.value
+		if entry.fresh(time.Now()) {
+			metrics.CacheRequests.WithLabelValues(userL1CacheName, metrics.CacheResultHit).Inc()
+			return &user, nil
+		}
+
+		metrics.CacheRequests.WithLabelValues(userL1CacheName, metrics.CacheResultStale).Inc()
+		go func() {
+			// Revalidation outlives the request that noticed the stale entry.
+			if _, err := c.load(context.WithoutCancel(ctx), userID, ttl, load); err != nil {
+				c.logger.Warn("cache[user]", "GetOrLoad", "Failed to revalidate stale user", err, userID)
+			}
+		}()
+		return &user, nil
 	}
+	metrics.CacheRequests.WithLabelValues(userL1CacheName, metrics.CacheResultMiss).Inc()
+
+	// A canceled caller must not fail the callers that share its load.
+	return c.load(context.WithoutCancel(ctx), userID, ttl, load)
+}
+
+func (c *UserCache) Delete(ctx context.Context, userID uuid.UUID) error {
+	c.l1.delete(userID)
+
+	return c.remote.Delete(ctx, userID)
+}
+
+// load fills L1 from Redis or from load, with at most one call in flight
+// per user.
+func (c *UserCache) load(ctx context.Context, userID uuid.UUID, ttl time.Duration, load func(ctx context.Context, userID uuid.UUID) (*model.User, error)) (*model.User, error) {
+	v, err, _ := c.group.Do(userID.String(), func() (interface{}, error) {
+		user, found, err := c.getRemote(ctx, userID)
+		if err != nil && !errors.Is(err, errBreakerOpen) {
+			c.logger.Warn("cache[user]", "load", "Failed to get user from redis", err, userID)
+		}
+		if found {
+			c.l1.set(userID, *user, jitter(c.cfg.L1TTL), c.cfg.StaleTTL)
+			return *user, nil
+		}
+
+		user, err = load(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.Set(ctx, userID, user, ttl); err != nil && !errors.Is(err, errBreakerOpen) {
+			c.logger.Warn("cache[user]", "load", "Failed to set user to redis", err, userID)
+		}
+		return *user, nil
+	})
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
-	var item cacheItem
-	if err := json.Unmarshal([]byte(val), &item); err != nil {
-		return nil, false, err
-	}
+	// Every caller gets its own copy of the shared result.
+	user := v.(model.User)
+	return &user, nil
+}
 
-	if time.Now().After(item.ExpiresAt) {
-		go c.client.Del(context.Background(), userID.String())
-		return nil, false, nil
+func (c *UserCache) getRemote(ctx context.Context, userID uuid.UUID) (*model.User, bool, error) {
+	user, found, err := c.remote.Get(ctx, userID)
+	if err != nil || !found {
+		return nil, false, err
 	}
+	return &user, true, nil
+}
 
-	return item.User, true, nil
+// redactUser drops the password hash, which has no business in a cache.
+func redactUser(user model.User) model.User {
+	user.Password = nil
+	return user
 }
 
-func (c *UserCache) Delete(ctx context.Context, userID uuid.UUID) error {
-	return c.client.Del(ctx, userID.String()).Err()
+// jitter spreads d by up to a tenth, so entries written together do not
+// expire together.
+func jitter(d time.Duration) time.Duration {
+	if d < 10 {
+		return d
+	}
+	return d + rand.N(d/10)
 }
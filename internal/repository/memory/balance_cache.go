@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/google/uuid"
+)
+
+type balanceCacheItem struct {
+	generation int64
+	balance    model.UserBalance
+	expiresAt  time.Time
+}
+
+// BalanceCache is an in-memory IBalanceCache. Like the Redis one it tags
+// balances with the generation they were read at, so a load that raced with
+// Invalidate is never served.
+type BalanceCache struct {
+	mu          sync.Mutex
+	items       map[uuid.UUID]balanceCacheItem
+	generations map[uuid.UUID]int64
+}
+
+func NewBalanceCache() *BalanceCache {
+	return &BalanceCache{
+		items:       make(map[uuid.UUID]balanceCacheItem),
+		generations: make(map[uuid.UUID]int64),
+	}
+}
+
+func (c *BalanceCache) GetOrLoad(ctx context.Context, userID uuid.UUID, ttl time.Duration, load func(ctx context.Context, userID uuid.UUID) (*model.UserBalance, error)) (*model.UserBalance, error) {
+	c.mu.Lock()
+	generation := c.generations[userID]
+	item, ok := c.items[userID]
+	c.mu.Unlock()
+
+	if ok && item.generation == generation && time.Now().Before(item.expiresAt) {
+		balance := copyBalance(&item.balance)
+		return &balance, nil
+	}
+
+	balance, err := load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[userID] = balanceCacheItem{
+		generation: generation,
+		balance:    copyBalance(balance),
+		expiresAt:  time.Now().Add(ttl),
+	}
+	return balance, nil
+}
+
+func (c *BalanceCache) Invalidate(_ context.Context, userID uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations[userID]++
+	delete(c.items, userID)
+	return nil
+}
+
+func copyBalance(balance *model.UserBalance) model.UserBalance {
+	c := model.UserBalance{
+		Current:   copyAmount(balance.Current),
+		Withdrawn: copyAmount(balance.Withdrawn),
+	}
+	if balance.UserID != nil {
+		userID := *balance.UserID
+		c.UserID = &userID
+	}
+	return c
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+)
+
+type idempotencyItem struct {
+	record    model.IdempotencyRecord
+	expiresAt time.Time
+}
+
+// IdempotencyCache is an in-memory IIdempotencyCache. Records expire after
+// their ttl like Redis keys do.
+type IdempotencyCache struct {
+	mu    sync.Mutex
+	items map[string]idempotencyItem
+}
+
+func NewIdempotencyCache() *IdempotencyCache {
+	return &IdempotencyCache{
+		items: make(map[string]idempotencyItem),
+	}
+}
+
+func (c *IdempotencyCache) Reserve(_ context.Context, key string, fingerprint string, ttl time.Duration) (*model.IdempotencyRecord, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.items[key]; ok && time.Now().Before(item.expiresAt) {
+		record := copyIdempotencyRecord(item.record)
+		return &record, false, nil
+	}
+
+	c.items[key] = idempotencyItem{
+		record:    model.IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil, true, nil
+}
+
+func (c *IdempotencyCache) Complete(_ context.Context, key string, record *model.IdempotencyRecord, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[key] = idempotencyItem{
+		record:    copyIdempotencyRecord(*record),
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (c *IdempotencyCache) Release(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+	return nil
+}
+
+func copyIdempotencyRecord(record model.IdempotencyRecord) model.IdempotencyRecord {
+	record.Body = append([]byte(nil), record.Body...)
+	return record
+}
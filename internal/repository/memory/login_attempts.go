@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type loginFailures struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// LoginAttemptCache is an in-memory ILoginAttemptCache. Counters and
+// lockouts expire like the Redis keys do.
+type LoginAttemptCache struct {
+	mu       sync.Mutex
+	failures map[string]loginFailures
+	lockouts map[string]time.Time
+}
+
+func NewLoginAttemptCache() *LoginAttemptCache {
+	return &LoginAttemptCache{
+		failures: make(map[string]loginFailures),
+		lockouts: make(map[string]time.Time),
+	}
+}
+
+func (c *LoginAttemptCache) GetLockout(_ context.Context, key string) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.lockouts[key]
+	if !ok {
+		return 0, nil
+	}
+	if d := time.Until(until); d > 0 {
+		return d, nil
+	}
+	delete(c.lockouts, key)
+	return 0, nil
+}
+
+func (c *LoginAttemptCache) SetLockout(_ context.Context, key string, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lockouts[key] = time.Now().Add(d)
+	return nil
+}
+
+func (c *LoginAttemptCache) RegisterFailure(_ context.Context, key string, window time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	failures := c.failures[key]
+	if !time.Now().Before(failures.expiresAt) {
+		failures.count = 0
+	}
+	failures.count++
+	failures.expiresAt = time.Now().Add(window)
+	c.failures[key] = failures
+
+	return failures.count, nil
+}
+
+func (c *LoginAttemptCache) ResetFailures(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.failures, key)
+	delete(c.lockouts, key)
+	return nil
+}
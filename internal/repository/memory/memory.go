@@ -0,0 +1,80 @@
+// Package memory holds in-memory implementations of the repository
+// interfaces. They keep the semantics of the Postgres and Redis backed ones,
+// so usecases can be run without either.
+package memory
+
+import (
+	"errors"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+)
+
+var (
+	_ repository.IUserRepository    = (*UserRepo)(nil)
+	_ repository.ITokenRepository   = (*TokenRepo)(nil)
+	_ repository.IUserCache         = (*UserCache)(nil)
+	_ repository.IBalanceCache      = (*BalanceCache)(nil)
+	_ repository.IIdempotencyCache  = (*IdempotencyCache)(nil)
+	_ repository.ITokenDenylist     = (*TokenDenylist)(nil)
+	_ repository.ILoginAttemptCache = (*LoginAttemptCache)(nil)
+	_ repository.IOrderEventBus     = (*OrderEventBus)(nil)
+	_ repository.ITxManager         = (*TxManager)(nil)
+)
+
+var errNoRowsRaw = errors.New("no rows in result set")
+
+func newPgError(code pghelpers.PgErrorCode, msg string) *pghelpers.PgError {
+	return &pghelpers.PgError{
+		Code:    code,
+		Message: msg,
+		RawErr:  errors.New(msg),
+	}
+}
+
+func errNoRows() *pghelpers.PgError {
+	return &pghelpers.PgError{
+		Code:    pghelpers.ErrNoRows,
+		Message: "no rows in resul set",
+		RawErr:  errNoRowsRaw,
+	}
+}
+
+// now matches the precision timestamps get back from Postgres.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func copyAmount(a *money.Amount) *money.Amount {
+	if a == nil {
+		return nil
+	}
+	c := *a
+	return &c
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+// New returns a Repository kept entirely in memory.
+func New() *repository.Repository {
+	users := NewUserRepo()
+	return &repository.Repository{
+		ITxManager:         NewTxManager(users),
+		IUserRepository:    users,
+		ITokenRepository:   NewTokenRepo(),
+		IUserCache:         NewUserCache(),
+		IBalanceCache:      NewBalanceCache(),
+		IIdempotencyCache:  NewIdempotencyCache(),
+		ITokenDenylist:     NewTokenDenylist(),
+		ILoginAttemptCache: NewLoginAttemptCache(),
+		IOrderEventBus:     NewOrderEventBus(),
+	}
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/google/uuid"
+)
+
+// orderEventsBuffer is how many events a subscriber may lag behind before
+// further ones are dropped, as Redis pub/sub does with slow readers.
+const orderEventsBuffer = 64
+
+type orderEventSubscriber struct {
+	events chan model.OrderEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+// OrderEventBus is an in-memory IOrderEventBus delivering events within the
+// process.
+type OrderEventBus struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[*orderEventSubscriber]struct{}
+}
+
+func NewOrderEventBus() *OrderEventBus {
+	return &OrderEventBus{
+		subscribers: make(map[uuid.UUID]map[*orderEventSubscriber]struct{}),
+	}
+}
+
+func (b *OrderEventBus) Publish(_ context.Context, event model.OrderEvent) error {
+	if event.UserID == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers[*event.UserID] {
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe streams events of userID until ctx is done or close is called.
+func (b *OrderEventBus) Subscribe(ctx context.Context, userID uuid.UUID) (<-chan model.OrderEvent, func() error, error) {
+	sub := &orderEventSubscriber{
+		events: make(chan model.OrderEvent, orderEventsBuffer),
+		done:   make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*orderEventSubscriber]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() error {
+		sub.once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userID], sub)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			b.mu.Unlock()
+			close(sub.done)
+		})
+		return nil
+	}
+
+	events := make(chan model.OrderEvent)
+	go func() {
+		defer close(events)
+		defer unsubscribe() //nolint:errcheck
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.done:
+				return
+			case event := <-sub.events:
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				case <-sub.done:
+					return
+				}
+			}
+		}
+	}()
+
+	return events, unsubscribe, nil
+}
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/google/uuid"
+)
+
+type refreshTokenRow struct {
+	id        uuid.UUID
+	userID    uuid.UUID
+	familyID  uuid.UUID
+	tokenHash string
+	expiresAt time.Time
+	createdAt time.Time
+	rotatedAt *time.Time
+	revokedAt *time.Time
+}
+
+// TokenRepo is an in-memory ITokenRepository. Unlike UserRepo its writes
+// are not undone when a TxManager unit of work is rolled back.
+type TokenRepo struct {
+	mu     sync.Mutex
+	tokens map[string]refreshTokenRow
+}
+
+func NewTokenRepo() *TokenRepo {
+	return &TokenRepo{
+		tokens: make(map[string]refreshTokenRow),
+	}
+}
+
+func (t *TokenRepo) CreateRefreshToken(_ context.Context, input model.CreateRefreshTokenInput) (*model.RefreshToken, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.createRefreshToken(input)
+}
+
+func (t *TokenRepo) GetRefreshTokenByHash(_ context.Context, tokenHash string) (*model.RefreshToken, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	row, ok := t.tokens[tokenHash]
+	if !ok {
+		return nil, errNoRows()
+	}
+	return row.toModel(), nil
+}
+
+// RotateRefreshToken follows the Postgres implementation: only an active
+// token is rotated, at most once, and next joins its user and family.
+func (t *TokenRepo) RotateRefreshToken(_ context.Context, tokenHash string, next model.CreateRefreshTokenInput) (*model.RefreshToken, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	row, ok := t.tokens[tokenHash]
+	if !ok || row.rotatedAt != nil || row.revokedAt != nil || !now().Before(row.expiresAt) {
+		return nil, errNoRows()
+	}
+
+	next.UserID = generics.Pointer(row.userID)
+	next.FamilyID = generics.Pointer(row.familyID)
+	created, err := t.createRefreshToken(next)
+	if err != nil {
+		return nil, err
+	}
+
+	row.rotatedAt = generics.Pointer(now())
+	t.tokens[tokenHash] = row
+	return created, nil
+}
+
+func (t *TokenRepo) RevokeRefreshTokenFamily(_ context.Context, familyID uuid.UUID) error {
+	t.revokeWhere(func(row refreshTokenRow) bool {
+		return row.familyID == familyID
+	})
+	return nil
+}
+
+func (t *TokenRepo) RevokeUserRefreshTokens(_ context.Context, userID uuid.UUID) error {
+	t.revokeWhere(func(row refreshTokenRow) bool {
+		return row.userID == userID
+	})
+	return nil
+}
+
+// createRefreshToken mirrors the refresh_token constraints. The caller must
+// hold the lock.
+func (t *TokenRepo) createRefreshToken(input model.CreateRefreshTokenInput) (*model.RefreshToken, error) {
+	if input.UserID == nil || input.FamilyID == nil || input.TokenHash == nil || input.ExpiresAt == nil {
+		return nil, newPgError(pghelpers.ErrNotNullViolation, "not null violation error")
+	}
+	if _, ok := t.tokens[*input.TokenHash]; ok {
+		return nil, newPgError(pghelpers.ErrUniqueViolation, "unique violation error")
+	}
+
+	row := refreshTokenRow{
+		id:        uuid.New(),
+		userID:    *input.UserID,
+		familyID:  *input.FamilyID,
+		tokenHash: *input.TokenHash,
+		expiresAt: input.ExpiresAt.Truncate(time.Microsecond),
+		createdAt: now(),
+	}
+	t.tokens[row.tokenHash] = row
+
+	return row.toModel(), nil
+}
+
+func (t *TokenRepo) revokeWhere(match func(row refreshTokenRow) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	revokedAt := now()
+	for hash, row := range t.tokens {
+		if row.revokedAt == nil && match(row) {
+			row.revokedAt = generics.Pointer(revokedAt)
+			t.tokens[hash] = row
+		}
+	}
+}
+
+func (r refreshTokenRow) toModel() *model.RefreshToken {
+	token := &model.RefreshToken{
+		ID:        generics.Pointer(r.id),
+		UserID:    generics.Pointer(r.userID),
+		FamilyID:  generics.Pointer(r.familyID),
+		TokenHash: generics.Pointer(r.tokenHash),
+		ExpiresAt: generics.Pointer(r.expiresAt),
+		CreatedAt: generics.Pointer(r.createdAt),
+	}
+	if r.rotatedAt != nil {
+		token.RotatedAt = generics.Pointer(*r.rotatedAt)
+	}
+	if r.revokedAt != nil {
+		token.RevokedAt = generics.Pointer(*r.revokedAt)
+	}
+	return token
+}
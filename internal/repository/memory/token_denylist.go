@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type userRevocation struct {
+	revokedAt time.Time
+	expiresAt time.Time
+}
+
+// TokenDenylist is an in-memory ITokenDenylist. Entries expire after their
+// ttl like Redis keys do, user revocations keep the second precision the
+// Redis one stores.
+type TokenDenylist struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	users  map[uuid.UUID]userRevocation
+}
+
+func NewTokenDenylist() *TokenDenylist {
+	return &TokenDenylist{
+		tokens: make(map[string]time.Time),
+		users:  make(map[uuid.UUID]userRevocation),
+	}
+}
+
+func (c *TokenDenylist) Add(_ context.Context, tokenID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokens[tokenID] = time.Now().Add(ttl)
+	return nil
+}
+
+func (c *TokenDenylist) Contains(_ context.Context, tokenID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt, ok := c.tokens[tokenID]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (c *TokenDenylist) RevokeUser(_ context.Context, userID uuid.UUID, revokedAt time.Time, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.users[userID] = userRevocation{
+		revokedAt: time.Unix(revokedAt.Unix(), 0),
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (c *TokenDenylist) UserRevokedBefore(_ context.Context, userID uuid.UUID) (time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	revocation, ok := c.users[userID]
+	if !ok || !time.Now().Before(revocation.expiresAt) {
+		return time.Time{}, nil
+	}
+	return revocation.revokedAt, nil
+}
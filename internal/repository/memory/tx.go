@@ -0,0 +1,71 @@
+package memory
+
+import "context"
+
+type txKey struct{}
+
+type txState struct {
+	afterCommit []func(ctx context.Context)
+}
+
+// TxManager is an in-memory ITxManager for a UserRepo. A unit of work holds
+// the repository for its whole run, other calls wait for it, and a failed
+// one is rolled back by restoring the state the repository had before it
+// started.
+type TxManager struct {
+	repo *UserRepo
+}
+
+func NewTxManager(repo *UserRepo) *TxManager {
+	return &TxManager{
+		repo: repo,
+	}
+}
+
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if inTx(ctx) {
+		return fn(ctx)
+	}
+
+	state := &txState{}
+	if err := m.run(ctx, state, fn); err != nil {
+		return err
+	}
+
+	for _, hook := range state.afterCommit {
+		hook(ctx)
+	}
+	return nil
+}
+
+func (m *TxManager) run(ctx context.Context, state *txState, fn func(ctx context.Context) error) error {
+	m.repo.txMu.Lock()
+	defer m.repo.txMu.Unlock()
+
+	snapshot := m.repo.snapshot()
+	defer func() {
+		if r := recover(); r != nil {
+			m.repo.restore(snapshot)
+			panic(r)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		m.repo.restore(snapshot)
+		return err
+	}
+	return nil
+}
+
+func (m *TxManager) AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+func inTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txState)
+	return ok
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+)
+
+func createUser(ctx context.Context, repo *UserRepo, login string) error {
+	_, err := repo.CreateUser(ctx, model.UserInput{
+		Login:    generics.Pointer(login),
+		Password: generics.Pointer("hash"),
+	})
+	return err
+}
+
+func TestWithinTx(t *testing.T) {
+	errRollback := errors.New("rollback")
+
+	tests := []struct {
+		name       string
+		fnErr      error
+		wantInTx   bool
+		wantHooked bool
+	}{
+		{name: "commit", wantInTx: true, wantHooked: true},
+		{name: "rollback", fnErr: errRollback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewUserRepo()
+			txManager := NewTxManager(repo)
+
+			inTx := make(chan struct{})
+			release := make(chan struct{})
+			outsideDone := make(chan error, 1)
+
+			var hooked bool
+			txErr := make(chan error, 1)
+			go func() {
+				txErr <- txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+					if err := createUser(ctx, repo, "in-tx"); err != nil {
+						return err
+					}
+					txManager.AfterCommit(ctx, func(context.Context) { hooked = true })
+					close(inTx)
+					<-release
+					return tt.fnErr
+				})
+			}()
+
+			<-inTx
+			go func() {
+				outsideDone <- createUser(context.Background(), repo, "outside")
+			}()
+
+			// Writes outside the unit of work wait for it to finish.
+			select {
+			case err := <-outsideDone:
+				t.Fatalf("write outside the transaction finished while it was running: %v", err)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			close(release)
+			if err := <-txErr; !errors.Is(err, tt.fnErr) {
+				t.Fatalf("WithinTx error = %v, want %v", err, tt.fnErr)
+			}
+			if err := <-outsideDone; err != nil {
+				t.Fatalf("write outside the transaction: %v", err)
+			}
+
+			if _, err := repo.GetUserByLogin(context.Background(), "outside"); err != nil {
+				t.Errorf("write outside the transaction was lost: %v", err)
+			}
+			_, err := repo.GetUserByLogin(context.Background(), "in-tx")
+			if gotInTx := err == nil; gotInTx != tt.wantInTx {
+				t.Errorf("write in the transaction kept = %v, want %v", gotInTx, tt.wantInTx)
+			}
+			if hooked != tt.wantHooked {
+				t.Errorf("after commit hook ran = %v, want %v", hooked, tt.wantHooked)
+			}
+		})
+	}
+}
+
+func TestWithinTxPanic(t *testing.T) {
+	repo := NewUserRepo()
+	txManager := NewTxManager(repo)
+
+	panicked := func() (panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		_ = txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+			if err := createUser(ctx, repo, "in-tx"); err != nil {
+				return err
+			}
+			panic("unit of work failed")
+		})
+		return false
+	}()
+
+	if !panicked {
+		t.Fatal("WithinTx swallowed the panic")
+	}
+	if _, err := repo.GetUserByLogin(context.Background(), "in-tx"); err == nil {
+		t.Error("write in the panicked transaction was kept")
+	}
+	if err := createUser(context.Background(), repo, "after"); err != nil {
+		t.Errorf("write after the panicked transaction: %v", err)
+	}
+}
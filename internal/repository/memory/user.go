@@ -0,0 +1,574 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+	"github.com/google/uuid"
+)
+
+type userRow struct {
+	id        uuid.UUID
+	login     string
+	password  string
+	createdAt time.Time
+}
+
+type orderRow struct {
+	id           uuid.UUID
+	userID       uuid.UUID
+	number       string
+	status       model.OrderStatusEnum
+	accrual      *money.Amount
+	uploadedAt   time.Time
+	pollAttempts int
+	nextPollAt   time.Time
+}
+
+type withdrawalRow struct {
+	id          uuid.UUID
+	userID      uuid.UUID
+	orderNumber string
+	sum         money.Amount
+	processedAt time.Time
+}
+
+type ledgerRow struct {
+	id             uuid.UUID
+	userID         uuid.UUID
+	entryType      model.LedgerEntryTypeEnum
+	debitAccount   model.LedgerAccountEnum
+	creditAccount  model.LedgerAccountEnum
+	amount         money.Amount
+	balanceAfter   money.Amount
+	withdrawnAfter money.Amount
+	orderNumber    *string
+	reversalOf     *uuid.UUID
+	createdAt      time.Time
+}
+
+type userState struct {
+	users       map[uuid.UUID]userRow
+	logins      map[string]uuid.UUID
+	orders      map[uuid.UUID]orderRow
+	numbers     map[string]uuid.UUID
+	withdrawals map[uuid.UUID]withdrawalRow
+	ledger      []ledgerRow
+}
+
+// UserRepo is an in-memory IUserRepository. It enforces the constraints of
+// the Postgres schema and reports violations and missing rows as the
+// matching *pghelpers.PgError, so usecases behave the same on top of it.
+type UserRepo struct {
+	// txMu is held by a unit of work of TxManager for its whole run. Calls
+	// made outside of it wait, so they neither see its writes before it
+	// commits nor have their own undone when it is rolled back.
+	txMu  sync.RWMutex
+	mu    sync.RWMutex
+	state userState
+}
+
+func NewUserRepo() *UserRepo {
+	return &UserRepo{
+		state: userState{
+			users:       make(map[uuid.UUID]userRow),
+			logins:      make(map[string]uuid.UUID),
+			orders:      make(map[uuid.UUID]orderRow),
+			numbers:     make(map[string]uuid.UUID),
+			withdrawals: make(map[uuid.UUID]withdrawalRow),
+		},
+	}
+}
+
+func (u *UserRepo) CreateUser(ctx context.Context, input model.UserInput) (*model.User, error) {
+	if input.Login == nil || input.Password == nil {
+		return nil, newPgError(pghelpers.ErrNotNullViolation, "not null violation error")
+	}
+
+	defer u.lock(ctx)()
+
+	if _, ok := u.state.logins[*input.Login]; ok {
+		return nil, newPgError(pghelpers.ErrUniqueViolation, "unique violation error")
+	}
+
+	row := userRow{
+		id:        uuid.New(),
+		login:     *input.Login,
+		password:  *input.Password,
+		createdAt: now(),
+	}
+	u.state.users[row.id] = row
+	u.state.logins[row.login] = row.id
+
+	return row.toModel(), nil
+}
+
+func (u *UserRepo) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
+	defer u.rlock(ctx)()
+
+	id, ok := u.state.logins[login]
+	if !ok {
+		return nil, errNoRows()
+	}
+	return u.state.users[id].toModel(), nil
+}
+
+func (u *UserRepo) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	defer u.rlock(ctx)()
+
+	row, ok := u.state.users[userID]
+	if !ok {
+		return nil, errNoRows()
+	}
+	return row.toModel(), nil
+}
+
+func (u *UserRepo) UpdateUserPassword(ctx context.Context, userID uuid.UUID, oldHash string, newHash string) error {
+	defer u.lock(ctx)()
+
+	row, ok := u.state.users[userID]
+	if !ok || row.password != oldHash {
+		return errNoRows()
+	}
+
+	row.password = newHash
+	u.state.users[userID] = row
+	return nil
+}
+
+func (u *UserRepo) CreateUserOrder(ctx context.Context, input model.UserOrderInput) (*model.UserOrder, error) {
+	if input.UserID == nil || input.Number == nil || input.Status == nil {
+		return nil, newPgError(pghelpers.ErrNotNullViolation, "not null violation error")
+	}
+
+	defer u.lock(ctx)()
+
+	if _, ok := u.state.users[*input.UserID]; !ok {
+		return nil, newPgError(pghelpers.ErrForeignKey, "foreign key violation error")
+	}
+	if _, ok := u.state.numbers[*input.Number]; ok {
+		return nil, newPgError(pghelpers.ErrUniqueViolation, "unique violation error")
+	}
+
+	row := orderRow{
+		id:         uuid.New(),
+		userID:     *input.UserID,
+		number:     *input.Number,
+		status:     *input.Status,
+		uploadedAt: now(),
+		nextPollAt: now(),
+	}
+	u.state.orders[row.id] = row
+	u.state.numbers[row.number] = row.id
+
+	return row.toModel(), nil
+}
+
+func (u *UserRepo) GetUserOrders(ctx context.Context, userID uuid.UUID, filter model.OrderListFilter) ([]model.UserOrder, error) {
+	defer u.rlock(ctx)()
+
+	var rows []orderRow
+	for _, row := range u.state.orders {
+		if row.userID != userID {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, row.status) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	rows = applyListFilter(rows, filter.ListFilter, func(row orderRow) (time.Time, uuid.UUID) {
+		return row.uploadedAt, row.id
+	})
+
+	var orders []model.UserOrder
+	for _, row := range rows {
+		orders = append(orders, *row.toModel())
+	}
+	return orders, nil
+}
+
+func (u *UserRepo) CheckUserOrderExists(ctx context.Context, number string, userID uuid.UUID) (bool, error) {
+	defer u.rlock(ctx)()
+
+	id, ok := u.state.numbers[number]
+	if !ok {
+		return false, nil
+	}
+	return u.state.orders[id].userID == userID, nil
+}
+
+// GetPendingOrders follows the Postgres implementation: due orders are
+// claimed by moving their next poll ahead by backoff.
+func (u *UserRepo) GetPendingOrders(ctx context.Context, limit uint64, backoff model.OrderPollBackoff) ([]model.UserOrder, error) {
+	defer u.lock(ctx)()
+
+	claimedAt := now()
+	var rows []orderRow
+	for _, row := range u.pendingOrders() {
+		if !row.nextPollAt.After(claimedAt) {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, func(a, b orderRow) int {
+		return compareKey(a.nextPollAt, a.id, b.nextPollAt, b.id)
+	})
+	if uint64(len(rows)) > limit {
+		rows = rows[:limit]
+	}
+
+	var orders []model.UserOrder
+	for _, row := range rows {
+		row.nextPollAt = claimedAt.Add(backoff.Delay(row.pollAttempts))
+		row.pollAttempts++
+		u.state.orders[row.id] = row
+		orders = append(orders, *row.toModel())
+	}
+	return orders, nil
+}
+
+func (u *UserRepo) GetOrderBacklog(ctx context.Context) (*model.OrderBacklog, error) {
+	defer u.rlock(ctx)()
+
+	rows := u.pendingOrders()
+
+	backlog := &model.OrderBacklog{Pending: int64(len(rows))}
+	if len(rows) > 0 {
+		backlog.OldestUploadedAt = generics.Pointer(rows[0].uploadedAt)
+	}
+	return backlog, nil
+}
+
+// UpdateUserOrderAccrual follows the Postgres implementation: only pending
+// orders are updated and a processed order credits its accrual to the
+// ledger.
+func (u *UserRepo) UpdateUserOrderAccrual(ctx context.Context, input model.UserOrderAccrualInput) (*model.UserOrder, error) {
+	if input.Number == nil || input.Status == nil {
+		return nil, newPgError(pghelpers.ErrNotNullViolation, "not null violation error")
+	}
+
+	defer u.lock(ctx)()
+
+	id, ok := u.state.numbers[*input.Number]
+	if !ok || !isPending(u.state.orders[id].status) {
+		return nil, errNoRows()
+	}
+
+	row := u.state.orders[id]
+	row.status = *input.Status
+	row.accrual = copyAmount(input.Accrual)
+	row.pollAttempts = 0
+	row.nextPollAt = now()
+
+	if row.status == model.OrderStatusEnumProcessed && row.accrual != nil && row.accrual.Minor() > 0 {
+		if err := u.appendLedgerEntry(ledgerRow{
+			userID:        row.userID,
+			entryType:     model.LedgerEntryTypeEnumAccrual,
+			debitAccount:  model.LedgerAccountEnumAccrual,
+			creditAccount: model.LedgerAccountEnumUser,
+			amount:        *row.accrual,
+			orderNumber:   generics.Pointer(row.number),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	u.state.orders[id] = row
+	return row.toModel(), nil
+}
+
+func (u *UserRepo) GetUserBalance(ctx context.Context, userID uuid.UUID) (*model.UserBalance, error) {
+	defer u.rlock(ctx)()
+
+	current, withdrawn := u.balance(userID)
+	return &model.UserBalance{
+		UserID:    generics.Pointer(userID),
+		Current:   generics.Pointer(current),
+		Withdrawn: generics.Pointer(withdrawn),
+	}, nil
+}
+
+func (u *UserRepo) CreateUserWithdrawal(ctx context.Context, input model.UserWithdrawalInput) (*model.UserWithdrawal, error) {
+	if input.UserID == nil || input.OrderNumber == nil || input.Sum == nil {
+		return nil, newPgError(pghelpers.ErrNotNullViolation, "not null violation error")
+	}
+
+	defer u.lock(ctx)()
+
+	if err := u.appendLedgerEntry(ledgerRow{
+		userID:        *input.UserID,
+		entryType:     model.LedgerEntryTypeEnumWithdrawal,
+		debitAccount:  model.LedgerAccountEnumUser,
+		creditAccount: model.LedgerAccountEnumWithdrawal,
+		amount:        *input.Sum,
+		orderNumber:   copyString(input.OrderNumber),
+	}); err != nil {
+		return nil, err
+	}
+
+	row := withdrawalRow{
+		id:          uuid.New(),
+		userID:      *input.UserID,
+		orderNumber: *input.OrderNumber,
+		sum:         *input.Sum,
+		processedAt: now(),
+	}
+	u.state.withdrawals[row.id] = row
+
+	return row.toModel(), nil
+}
+
+func (u *UserRepo) GetUserWithdrawals(ctx context.Context, userID uuid.UUID, filter model.ListFilter) ([]model.UserWithdrawal, error) {
+	defer u.rlock(ctx)()
+
+	var rows []withdrawalRow
+	for _, row := range u.state.withdrawals {
+		if row.userID == userID {
+			rows = append(rows, row)
+		}
+	}
+
+	rows = applyListFilter(rows, filter, func(row withdrawalRow) (time.Time, uuid.UUID) {
+		return row.processedAt, row.id
+	})
+
+	var withdrawals []model.UserWithdrawal
+	for _, row := range rows {
+		withdrawals = append(withdrawals, *row.toModel())
+	}
+	return withdrawals, nil
+}
+
+func (u *UserRepo) GetUserLedger(ctx context.Context, userID uuid.UUID) ([]model.LedgerEntry, error) {
+	defer u.rlock(ctx)()
+
+	var entries []model.LedgerEntry
+	for _, row := range u.state.ledger {
+		if row.userID == userID {
+			entries = append(entries, *row.toModel())
+		}
+	}
+	return entries, nil
+}
+
+// appendLedgerEntry mirrors the ledger_entry constraints. The caller must
+// hold the write lock.
+func (u *UserRepo) appendLedgerEntry(entry ledgerRow) error {
+	if _, ok := u.state.users[entry.userID]; !ok {
+		return newPgError(pghelpers.ErrForeignKey, "foreign key violation error")
+	}
+	if entry.amount.Minor() <= 0 {
+		return newPgError(pghelpers.ErrCheckViolation, "check violation error")
+	}
+
+	current, withdrawn := u.balance(entry.userID)
+	balance, spent := current.Minor(), withdrawn.Minor()
+
+	if entry.creditAccount == model.LedgerAccountEnumUser {
+		balance += entry.amount.Minor()
+	}
+	if entry.debitAccount == model.LedgerAccountEnumUser {
+		balance -= entry.amount.Minor()
+	}
+	if entry.entryType == model.LedgerEntryTypeEnumWithdrawal {
+		spent += entry.amount.Minor()
+	}
+
+	if balance < 0 || spent < 0 {
+		return newPgError(pghelpers.ErrCheckViolation, "check violation error")
+	}
+
+	entry.id = uuid.New()
+	entry.balanceAfter = money.FromMinor(balance)
+	entry.withdrawnAfter = money.FromMinor(spent)
+	entry.createdAt = now()
+	u.state.ledger = append(u.state.ledger, entry)
+
+	return nil
+}
+
+// balance returns the running totals of the latest posting of userID. The
+// caller must hold the lock.
+func (u *UserRepo) balance(userID uuid.UUID) (money.Amount, money.Amount) {
+	for i := len(u.state.ledger) - 1; i >= 0; i-- {
+		if entry := u.state.ledger[i]; entry.userID == userID {
+			return entry.balanceAfter, entry.withdrawnAfter
+		}
+	}
+	return money.FromMinor(0), money.FromMinor(0)
+}
+
+// pendingOrders returns orders waiting for their accrual, oldest first. The
+// caller must hold the lock.
+func (u *UserRepo) pendingOrders() []orderRow {
+	var rows []orderRow
+	for _, row := range u.state.orders {
+		if isPending(row.status) {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, func(a, b orderRow) int {
+		return compareKey(a.uploadedAt, a.id, b.uploadedAt, b.id)
+	})
+	return rows
+}
+
+// lock takes the repository for a write made with ctx and returns the
+// matching unlock.
+func (u *UserRepo) lock(ctx context.Context) func() {
+	if !inTx(ctx) {
+		u.txMu.Lock()
+		u.mu.Lock()
+		return func() {
+			u.mu.Unlock()
+			u.txMu.Unlock()
+		}
+	}
+	u.mu.Lock()
+	return u.mu.Unlock
+}
+
+// rlock takes the repository for a read made with ctx and returns the
+// matching unlock.
+func (u *UserRepo) rlock(ctx context.Context) func() {
+	if !inTx(ctx) {
+		u.txMu.RLock()
+		u.mu.RLock()
+		return func() {
+			u.mu.RUnlock()
+			u.txMu.RUnlock()
+		}
+	}
+	u.mu.RLock()
+	return u.mu.RUnlock
+}
+
+// snapshot copies the state, the caller must hold txMu.
+func (u *UserRepo) snapshot() userState {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	return userState{
+		users:       maps.Clone(u.state.users),
+		logins:      maps.Clone(u.state.logins),
+		orders:      maps.Clone(u.state.orders),
+		numbers:     maps.Clone(u.state.numbers),
+		withdrawals: maps.Clone(u.state.withdrawals),
+		ledger:      slices.Clone(u.state.ledger),
+	}
+}
+
+// restore puts back a snapshot, the caller must hold txMu.
+func (u *UserRepo) restore(state userState) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.state = state
+}
+
+// applyListFilter does in memory what the keyset pagination query does in
+// Postgres: range, cursor, ordering by (time, id) and limit.
+func applyListFilter[T any](rows []T, filter model.ListFilter, key func(T) (time.Time, uuid.UUID)) []T {
+	desc := filter.Sort == model.SortDirectionEnumDesc
+
+	filtered := rows[:0]
+	for _, row := range rows {
+		t, id := key(row)
+		if filter.From != nil && t.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !t.Before(*filter.To) {
+			continue
+		}
+		if filter.After != nil {
+			cmp := compareKey(t, id, filter.After.Time, filter.After.ID)
+			if (!desc && cmp <= 0) || (desc && cmp >= 0) {
+				continue
+			}
+		}
+		filtered = append(filtered, row)
+	}
+
+	slices.SortFunc(filtered, func(a, b T) int {
+		at, aid := key(a)
+		bt, bid := key(b)
+		if desc {
+			return compareKey(bt, bid, at, aid)
+		}
+		return compareKey(at, aid, bt, bid)
+	})
+
+	if filter.Limit > 0 && uint64(len(filtered)) > filter.Limit {
+		filtered = filtered[:filter.Limit]
+	}
+	return filtered
+}
+
+func compareKey(at time.Time, aid uuid.UUID, bt time.Time, bid uuid.UUID) int {
+	if c := at.Compare(bt); c != 0 {
+		return c
+	}
+	return bytes.Compare(aid[:], bid[:])
+}
+
+func isPending(status model.OrderStatusEnum) bool {
+	return status == model.OrderStatusEnumNew || status == model.OrderStatusEnumProcessing
+}
+
+func (r userRow) toModel() *model.User {
+	return &model.User{
+		ID:        generics.Pointer(r.id),
+		Login:     generics.Pointer(r.login),
+		Password:  generics.Pointer(r.password),
+		CreatedAt: generics.Pointer(r.createdAt),
+	}
+}
+
+func (r orderRow) toModel() *model.UserOrder {
+	return &model.UserOrder{
+		ID:         generics.Pointer(r.id),
+		UserID:     generics.Pointer(r.userID),
+		Number:     generics.Pointer(r.number),
+		Status:     generics.Pointer(r.status),
+		Accrual:    copyAmount(r.accrual),
+		UploadedAt: generics.Pointer(r.uploadedAt),
+	}
+}
+
+func (r withdrawalRow) toModel() *model.UserWithdrawal {
+	return &model.UserWithdrawal{
+		ID:          generics.Pointer(r.id),
+		UserID:      generics.Pointer(r.userID),
+		OrderNumber: generics.Pointer(r.orderNumber),
+		Sum:         generics.Pointer(r.sum),
+		ProcessedAt: generics.Pointer(r.processedAt),
+	}
+}
+
+func (r ledgerRow) toModel() *model.LedgerEntry {
+	entry := &model.LedgerEntry{
+		ID:             generics.Pointer(r.id),
+		UserID:         generics.Pointer(r.userID),
+		EntryType:      generics.Pointer(r.entryType),
+		DebitAccount:   generics.Pointer(r.debitAccount),
+		CreditAccount:  generics.Pointer(r.creditAccount),
+		Amount:         generics.Pointer(r.amount),
+		BalanceAfter:   generics.Pointer(r.balanceAfter),
+		WithdrawnAfter: generics.Pointer(r.withdrawnAfter),
+		OrderNumber:    copyString(r.orderNumber),
+		CreatedAt:      generics.Pointer(r.createdAt),
+	}
+	if r.reversalOf != nil {
+		entry.ReversalOf = generics.Pointer(*r.reversalOf)
+	}
+	return entry
+}
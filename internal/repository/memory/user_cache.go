@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/google/uuid"
+)
+
+type userCacheItem struct {
+	user      model.User
+	expiresAt time.Time
+}
+
+// UserCache is an in-memory IUserCache. Entries expire after their ttl like
+// Redis keys do.
+type UserCache struct {
+	mu    sync.Mutex
+	items map[uuid.UUID]userCacheItem
+}
+
+func NewUserCache() *UserCache {
+	return &UserCache{
+		items: make(map[uuid.UUID]userCacheItem),
+	}
+}
+
+func (c *UserCache) Set(_ context.Context, userID uuid.UUID, user *model.User, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Like the Redis cache, never keep the password hash.
+	cached := copyUser(user)
+	cached.Password = nil
+
+	c.items[userID] = userCacheItem{
+		user:      cached,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (c *UserCache) Get(_ context.Context, userID uuid.UUID) (*model.User, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[userID]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if !time.Now().Before(item.expiresAt) {
+		delete(c.items, userID)
+		return nil, false, nil
+	}
+
+	user := copyUser(&item.user)
+	return &user, true, nil
+}
+
+func (c *UserCache) GetOrLoad(ctx context.Context, userID uuid.UUID, ttl time.Duration, load func(ctx context.Context, userID uuid.UUID) (*model.User, error)) (*model.User, error) {
+	if user, found, _ := c.Get(ctx, userID); found {
+		return user, nil
+	}
+
+	user, err := load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = c.Set(ctx, userID, user, ttl)
+	return user, nil
+}
+
+func (c *UserCache) Delete(_ context.Context, userID uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, userID)
+	return nil
+}
+
+// copyUser detaches the cached value from the caller's pointers, the same
+// way the JSON round trip through Redis does.
+func copyUser(user *model.User) model.User {
+	if user == nil {
+		return model.User{}
+	}
+
+	c := model.User{
+		Login:    copyString(user.Login),
+		Password: copyString(user.Password),
+	}
+	if user.ID != nil {
+		id := *user.ID
+		c.ID = &id
+	}
+	if user.CreatedAt != nil {
+		createdAt := *user.CreatedAt
+		c.CreatedAt = &createdAt
+	}
+	return c
+}
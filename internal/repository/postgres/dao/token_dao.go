@@ -0,0 +1,49 @@
+package dao
+
+import (
+	"database/sql"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/google/uuid"
+)
+
+type RefreshTokenDAO struct {
+	ID        uuid.NullUUID
+	UserID    uuid.NullUUID
+	FamilyID  uuid.NullUUID
+	TokenHash sql.NullString
+	ExpiresAt sql.NullTime
+	CreatedAt sql.NullTime
+	RotatedAt sql.NullTime
+	RevokedAt sql.NullTime
+}
+
+func (t *RefreshTokenDAO) ToModel() *model.RefreshToken {
+	return &model.RefreshToken{
+		ID:        pghelpers.FromNullUUID(t.ID),
+		UserID:    pghelpers.FromNullUUID(t.UserID),
+		FamilyID:  pghelpers.FromNullUUID(t.FamilyID),
+		TokenHash: pghelpers.FromNullString(t.TokenHash),
+		ExpiresAt: pghelpers.FromNullTime(t.ExpiresAt),
+		CreatedAt: pghelpers.FromNullTime(t.CreatedAt),
+		RotatedAt: pghelpers.FromNullTime(t.RotatedAt),
+		RevokedAt: pghelpers.FromNullTime(t.RevokedAt),
+	}
+}
+
+type CreateRefreshTokenInputDAO struct {
+	UserID    uuid.NullUUID
+	FamilyID  uuid.NullUUID
+	TokenHash sql.NullString
+	ExpiresAt sql.NullTime
+}
+
+func (t *CreateRefreshTokenInputDAO) FromModel(input model.CreateRefreshTokenInput) CreateRefreshTokenInputDAO {
+	return CreateRefreshTokenInputDAO{
+		UserID:    pghelpers.ToNullUUID(input.UserID),
+		FamilyID:  pghelpers.ToNullUUID(input.FamilyID),
+		TokenHash: pghelpers.ToNullString(input.TokenHash),
+		ExpiresAt: pghelpers.ToNullTime(input.ExpiresAt),
+	}
+}
@@ -51,7 +51,7 @@ func (o *UserOrderDAO) ToModel() *model.UserOrder {
 		UserID:     pghelpers.FromNullUUID(o.UserID),
 		Number:     pghelpers.FromNullString(o.Number),
 		Status:     (*model.OrderStatusEnum)(&o.Status.String),
-		Accrual:    pghelpers.FromNullInt64(o.Accrual),
+		Accrual:    pghelpers.FromNullMoney(o.Accrual),
 		UploadedAt: pghelpers.FromNullTime(o.UploadedAt),
 	}
 }
@@ -70,17 +70,31 @@ func (o *UserOrderInputDAO) FromModel(input model.UserOrderInput) UserOrderInput
 	}
 }
 
+type UserOrderAccrualInputDAO struct {
+	Number  sql.NullString
+	Status  sql.NullString
+	Accrual sql.NullInt64
+}
+
+func (o *UserOrderAccrualInputDAO) FromModel(input model.UserOrderAccrualInput) UserOrderAccrualInputDAO {
+	return UserOrderAccrualInputDAO{
+		Number:  pghelpers.ToNullString(input.Number),
+		Status:  pghelpers.ToNullString((*string)(input.Status)),
+		Accrual: pghelpers.ToNullMoney(input.Accrual),
+	}
+}
+
 type UserBalanceDAO struct {
 	UserID    uuid.NullUUID
 	Current   sql.NullInt64
 	Withdrawn sql.NullInt64
 }
 
-func (b *UserBalanceDAO) ToModel() *model.UserBalance[int64] {
-	return &model.UserBalance[int64]{
+func (b *UserBalanceDAO) ToModel() *model.UserBalance {
+	return &model.UserBalance{
 		UserID:    pghelpers.FromNullUUID(b.UserID),
-		Current:   pghelpers.FromNullInt64(b.Current),
-		Withdrawn: pghelpers.FromNullInt64(b.Withdrawn),
+		Current:   pghelpers.FromNullMoney(b.Current),
+		Withdrawn: pghelpers.FromNullMoney(b.Withdrawn),
 	}
 }
 
@@ -92,12 +106,12 @@ type UserWithdrawalDAO struct {
 	ProcessedAt sql.NullTime
 }
 
-func (w *UserWithdrawalDAO) ToModel() *model.UserWithdrawal[int64] {
-	return &model.UserWithdrawal[int64]{
+func (w *UserWithdrawalDAO) ToModel() *model.UserWithdrawal {
+	return &model.UserWithdrawal{
 		ID:          pghelpers.FromNullUUID(w.ID),
 		UserID:      pghelpers.FromNullUUID(w.UserID),
 		OrderNumber: pghelpers.FromNullString(w.OrderNumber),
-		Sum:         pghelpers.FromNullInt64(w.Sum),
+		Sum:         pghelpers.FromNullMoney(w.Sum),
 		ProcessedAt: pghelpers.FromNullTime(w.ProcessedAt),
 	}
 }
@@ -108,10 +122,64 @@ type UserWithdrawalInputDAO struct {
 	Sum         sql.NullInt64
 }
 
-func (w *UserWithdrawalInputDAO) FromModel(input model.UserWithdrawalInput[int64]) UserWithdrawalInputDAO {
+func (w *UserWithdrawalInputDAO) FromModel(input model.UserWithdrawalInput) UserWithdrawalInputDAO {
 	return UserWithdrawalInputDAO{
 		UserID:      pghelpers.ToNullUUID(input.UserID),
 		OrderNumber: pghelpers.ToNullString(input.OrderNumber),
-		Sum:         pghelpers.ToNullInt64(input.Sum),
+		Sum:         pghelpers.ToNullMoney(input.Sum),
+	}
+}
+
+type LedgerEntryDAO struct {
+	ID             uuid.NullUUID
+	UserID         uuid.NullUUID
+	EntryType      sql.NullString
+	DebitAccount   sql.NullString
+	CreditAccount  sql.NullString
+	Amount         sql.NullInt64
+	BalanceAfter   sql.NullInt64
+	WithdrawnAfter sql.NullInt64
+	OrderNumber    sql.NullString
+	ReversalOf     uuid.NullUUID
+	CreatedAt      sql.NullTime
+}
+
+func (l *LedgerEntryDAO) ToModel() *model.LedgerEntry {
+	return &model.LedgerEntry{
+		ID:             pghelpers.FromNullUUID(l.ID),
+		UserID:         pghelpers.FromNullUUID(l.UserID),
+		EntryType:      (*model.LedgerEntryTypeEnum)(pghelpers.FromNullString(l.EntryType)),
+		DebitAccount:   (*model.LedgerAccountEnum)(pghelpers.FromNullString(l.DebitAccount)),
+		CreditAccount:  (*model.LedgerAccountEnum)(pghelpers.FromNullString(l.CreditAccount)),
+		Amount:         pghelpers.FromNullMoney(l.Amount),
+		BalanceAfter:   pghelpers.FromNullMoney(l.BalanceAfter),
+		WithdrawnAfter: pghelpers.FromNullMoney(l.WithdrawnAfter),
+		OrderNumber:    pghelpers.FromNullString(l.OrderNumber),
+		ReversalOf:     pghelpers.FromNullUUID(l.ReversalOf),
+		CreatedAt:      pghelpers.FromNullTime(l.CreatedAt),
+	}
+}
+
+type LedgerEntryInputDAO struct {
+	UserID         uuid.NullUUID
+	EntryType      sql.NullString
+	DebitAccount   sql.NullString
+	CreditAccount  sql.NullString
+	Amount         sql.NullInt64
+	BalanceAfter   sql.NullInt64
+	WithdrawnAfter sql.NullInt64
+	OrderNumber    sql.NullString
+	ReversalOf     uuid.NullUUID
+}
+
+func (l *LedgerEntryInputDAO) FromModel(input model.LedgerEntryInput) LedgerEntryInputDAO {
+	return LedgerEntryInputDAO{
+		UserID:        pghelpers.ToNullUUID(input.UserID),
+		EntryType:     pghelpers.ToNullString((*string)(input.EntryType)),
+		DebitAccount:  pghelpers.ToNullString((*string)(input.DebitAccount)),
+		CreditAccount: pghelpers.ToNullString((*string)(input.CreditAccount)),
+		Amount:        pghelpers.ToNullMoney(input.Amount),
+		OrderNumber:   pghelpers.ToNullString(input.OrderNumber),
+		ReversalOf:    pghelpers.ToNullUUID(input.ReversalOf),
 	}
 }
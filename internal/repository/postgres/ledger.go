@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/repository/postgres/dao"
+	"github.com/FlyKarlik/gofemart/internal/repository/postgres/quries"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/google/uuid"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// appendLedgerEntry posts input to the ledger, q must be a transaction. Postings of the same
+// user are serialized by locking the user row, so the running totals are
+// computed from the latest entry without races. A posting that would take
+// the balance below zero fails with a check violation.
+func (u *UserRepo) appendLedgerEntry(ctx context.Context, q rowQuerier, input model.LedgerEntryInput) (*dao.LedgerEntryDAO, error) {
+	entryDAO := new(dao.LedgerEntryInputDAO).FromModel(input)
+
+	lockQuery, lockArgs, err := quries.BuildLockUserLedgerQuery(entryDAO.UserID)
+	if err != nil {
+		u.logger.Error("postgres[ledger]", "appendLedgerEntry", "Failed to build lock query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	var lockedID uuid.NullUUID
+	if err := q.QueryRow(ctx, lockQuery, lockArgs...).Scan(&lockedID); err != nil {
+		u.logger.Error("postgres[ledger]", "appendLedgerEntry", "Failed to lock user ledger", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	balance, err := u.getUserBalance(ctx, q, entryDAO.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	entryDAO.BalanceAfter = balance.Current
+	entryDAO.WithdrawnAfter = balance.Withdrawn
+	applyLedgerEntry(&entryDAO)
+
+	query, args, err := quries.BuildInsertLedgerEntryQuery(entryDAO)
+	if err != nil {
+		u.logger.Error("postgres[ledger]", "appendLedgerEntry", "Failed to build insert query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	var resultDAO dao.LedgerEntryDAO
+	if err := q.QueryRow(ctx, query, args...).Scan(
+		&resultDAO.ID,
+		&resultDAO.UserID,
+		&resultDAO.EntryType,
+		&resultDAO.DebitAccount,
+		&resultDAO.CreditAccount,
+		&resultDAO.Amount,
+		&resultDAO.BalanceAfter,
+		&resultDAO.WithdrawnAfter,
+		&resultDAO.OrderNumber,
+		&resultDAO.ReversalOf,
+		&resultDAO.CreatedAt,
+	); err != nil {
+		u.logger.Error("postgres[ledger]", "appendLedgerEntry", "Failed to insert ledger entry", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	return &resultDAO, nil
+}
+
+// applyLedgerEntry moves the running totals carried by entry from the
+// previous posting to the state after it.
+func applyLedgerEntry(entry *dao.LedgerEntryInputDAO) {
+	amount := entry.Amount.Int64
+
+	if entry.CreditAccount.String == model.LedgerAccountEnumUser.String() {
+		entry.BalanceAfter.Int64 += amount
+	}
+	if entry.DebitAccount.String == model.LedgerAccountEnumUser.String() {
+		entry.BalanceAfter.Int64 -= amount
+	}
+
+	switch {
+	case entry.EntryType.String == model.LedgerEntryTypeEnumWithdrawal.String():
+		entry.WithdrawnAfter.Int64 += amount
+	case entry.EntryType.String == model.LedgerEntryTypeEnumReversal.String() &&
+		entry.DebitAccount.String == model.LedgerAccountEnumWithdrawal.String():
+		entry.WithdrawnAfter.Int64 -= amount
+	}
+
+	entry.BalanceAfter.Valid = true
+	entry.WithdrawnAfter.Valid = true
+}
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (u *UserRepo) getUserBalance(ctx context.Context, q rowQuerier, userID uuid.NullUUID) (*dao.UserBalanceDAO, error) {
+	query, args, err := quries.BuildGetUserBalanceQuery(userID)
+	if err != nil {
+		u.logger.Error("postgres[ledger]", "getUserBalance", "Failed to build query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	var userBalanceDAO dao.UserBalanceDAO
+	if err := q.QueryRow(ctx, query, args...).Scan(
+		&userBalanceDAO.UserID,
+		&userBalanceDAO.Current,
+		&userBalanceDAO.Withdrawn,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &dao.UserBalanceDAO{
+				UserID:    userID,
+				Current:   pghelpers.ToNullInt64(new(int64)),
+				Withdrawn: pghelpers.ToNullInt64(new(int64)),
+			}, nil
+		}
+		u.logger.Error("postgres[ledger]", "getUserBalance", "Query failed", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	return &userBalanceDAO, nil
+}
+
+func (u *UserRepo) GetUserLedger(ctx context.Context, userID uuid.UUID) ([]model.LedgerEntry, error) {
+	query, args, err := quries.BuildGetUserLedgerQuery(pghelpers.ToNullUUID(&userID))
+	if err != nil {
+		u.logger.Error("postgres[ledger]", "GetUserLedger", "Failed to build query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	rows, err := conn(ctx, u.c).Query(ctx, query, args...)
+	if err != nil {
+		u.logger.Error("postgres[ledger]", "GetUserLedger", "Failed to execute query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+	defer rows.Close()
+
+	var entries []model.LedgerEntry
+	for rows.Next() {
+		var entry dao.LedgerEntryDAO
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.EntryType,
+			&entry.DebitAccount,
+			&entry.CreditAccount,
+			&entry.Amount,
+			&entry.BalanceAfter,
+			&entry.WithdrawnAfter,
+			&entry.OrderNumber,
+			&entry.ReversalOf,
+			&entry.CreatedAt,
+		); err != nil {
+			u.logger.Error("postgres[ledger]", "GetUserLedger", "Failed to scan row", err)
+			return nil, pghelpers.WrapError(err)
+		}
+		entries = append(entries, *entry.ToModel())
+	}
+
+	if err := rows.Err(); err != nil {
+		u.logger.Error("postgres[ledger]", "GetUserLedger", "Rows error", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	return entries, nil
+}
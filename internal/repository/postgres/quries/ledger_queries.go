@@ -0,0 +1,87 @@
+package quries
+
+import (
+	"strings"
+
+	"github.com/FlyKarlik/gofemart/internal/repository/postgres/dao"
+	"github.com/google/uuid"
+
+	"github.com/Masterminds/squirrel"
+)
+
+var ledgerEntryColumns = []string{
+	"id",
+	"user_id",
+	"entry_type",
+	"debit_account",
+	"credit_account",
+	"amount",
+	"balance_after",
+	"withdrawn_after",
+	"order_number",
+	"reversal_of",
+	"created_at",
+}
+
+// BuildLockUserLedgerQuery serializes postings of one user. FOR NO KEY UPDATE
+// does not block concurrent inserts referencing the user.
+func BuildLockUserLedgerQuery(userID uuid.NullUUID) (string, []interface{}, error) {
+	return squirrel.
+		Select("id").
+		From(`"user"`).
+		Where(squirrel.Eq{"id": userID}).
+		Suffix("FOR NO KEY UPDATE").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+func BuildGetUserBalanceQuery(userID uuid.NullUUID) (string, []interface{}, error) {
+	return squirrel.
+		Select("user_id", "balance_after", "withdrawn_after").
+		From("ledger_entry").
+		Where(squirrel.Eq{"user_id": userID}).
+		OrderBy("seq DESC").
+		Limit(1).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+func BuildInsertLedgerEntryQuery(entry dao.LedgerEntryInputDAO) (string, []interface{}, error) {
+	return squirrel.
+		Insert("ledger_entry").
+		Columns(
+			"user_id",
+			"entry_type",
+			"debit_account",
+			"credit_account",
+			"amount",
+			"balance_after",
+			"withdrawn_after",
+			"order_number",
+			"reversal_of",
+		).
+		Values(
+			entry.UserID,
+			entry.EntryType,
+			entry.DebitAccount,
+			entry.CreditAccount,
+			entry.Amount,
+			entry.BalanceAfter,
+			entry.WithdrawnAfter,
+			entry.OrderNumber,
+			entry.ReversalOf,
+		).
+		Suffix("RETURNING " + strings.Join(ledgerEntryColumns, ", ")).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+func BuildGetUserLedgerQuery(userID uuid.NullUUID) (string, []interface{}, error) {
+	return squirrel.
+		Select(ledgerEntryColumns...).
+		From("ledger_entry").
+		Where(squirrel.Eq{"user_id": userID}).
+		OrderBy("seq ASC").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
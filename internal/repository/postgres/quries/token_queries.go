@@ -0,0 +1,70 @@
+package quries
+
+import (
+	"database/sql"
+
+	"github.com/FlyKarlik/gofemart/internal/repository/postgres/dao"
+	"github.com/google/uuid"
+
+	"github.com/Masterminds/squirrel"
+)
+
+func BuildCreateRefreshTokenQuery(token dao.CreateRefreshTokenInputDAO) (string, []interface{}, error) {
+	return squirrel.
+		Insert("refresh_token").
+		Columns("user_id", "family_id", "token_hash", "expires_at").
+		Values(token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).
+		Suffix("RETURNING *").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+func BuildGetRefreshTokenByHashQuery(tokenHash sql.NullString) (string, []interface{}, error) {
+	return squirrel.
+		Select("*").
+		From("refresh_token").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+// BuildRotateRefreshTokenQuery marks an active token as rotated. The
+// conditions make the update succeed at most once per token.
+func BuildRotateRefreshTokenQuery(tokenHash sql.NullString) (string, []interface{}, error) {
+	return squirrel.
+		Update("refresh_token").
+		Set("rotated_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{
+			"token_hash": tokenHash,
+			"rotated_at": nil,
+			"revoked_at": nil,
+		}).
+		Where("expires_at > now()").
+		Suffix("RETURNING *").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+func BuildRevokeRefreshTokenFamilyQuery(familyID uuid.NullUUID) (string, []interface{}, error) {
+	return squirrel.
+		Update("refresh_token").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{
+			"family_id":  familyID,
+			"revoked_at": nil,
+		}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+func BuildRevokeUserRefreshTokensQuery(userID uuid.NullUUID) (string, []interface{}, error) {
+	return squirrel.
+		Update("refresh_token").
+		Set("revoked_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{
+			"user_id":    userID,
+			"revoked_at": nil,
+		}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
@@ -2,13 +2,29 @@ package quries
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 
+	"github.com/FlyKarlik/gofemart/internal/model"
 	"github.com/FlyKarlik/gofemart/internal/repository/postgres/dao"
 	"github.com/google/uuid"
 
 	"github.com/Masterminds/squirrel"
 )
 
+// userOrderColumns are the columns of user_order scanned into a
+// dao.UserOrderDAO, in scan order.
+var userOrderColumns = []string{
+	"id",
+	"user_id",
+	"number",
+	"status",
+	"accrual",
+	"uploaded_at",
+}
+
+var returningUserOrder = "RETURNING " + strings.Join(userOrderColumns, ", ")
+
 func BuildCreateUserQuery(user dao.UserInputDAO) (string, []interface{}, error) {
 	query, args, err := squirrel.
 		Insert(`"user"`).
@@ -55,12 +71,26 @@ func BuildGetUserByIDQuery(id uuid.NullUUID) (string, []interface{}, error) {
 	return query, args, nil
 }
 
+// BuildUpdateUserPasswordQuery replaces the password hash only while it is
+// still oldHash, so of two concurrent changes only one wins.
+func BuildUpdateUserPasswordQuery(id uuid.NullUUID, oldHash sql.NullString, newHash sql.NullString) (string, []interface{}, error) {
+	return squirrel.
+		Update(`"user"`).
+		Set("password_hash", newHash).
+		Where(squirrel.Eq{
+			"id":            id,
+			"password_hash": oldHash,
+		}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
 func BuildCreateOrderQuery(order dao.UserOrderInputDAO) (string, []interface{}, error) {
 	query, args, err := squirrel.
 		Insert(`"user_order"`).
 		Columns("number", "user_id", "status").
 		Values(order.Number, order.UserID, order.Status).
-		Suffix("RETURNING *").
+		Suffix(returningUserOrder).
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 
@@ -81,48 +111,60 @@ func BuildCheckOrderExistsQuery(number sql.NullString, userID uuid.NullUUID) (st
 	return query, args, err
 }
 
-func BuildGetUserOrdersQuery(userID uuid.NullUUID) (string, []interface{}, error) {
+func BuildGetUserOrdersQuery(userID uuid.NullUUID, filter model.OrderListFilter) (string, []interface{}, error) {
 	query := squirrel.
-		Select("*").
+		Select(userOrderColumns...).
 		From(`"user_order"`).
 		Where(squirrel.Eq{"user_id": userID}).
-		OrderBy("uploaded_at ASC").
 		PlaceholderFormat(squirrel.Dollar)
 
-	return query.ToSql()
-}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, 0, len(filter.Statuses))
+		for _, status := range filter.Statuses {
+			statuses = append(statuses, status.String())
+		}
+		query = query.Where(squirrel.Eq{"status": statuses})
+	}
 
-func BuildCreateUserBalanceQuery(userID uuid.NullUUID) (string, []interface{}, error) {
-	return squirrel.
-		Insert("user_balance").
-		Columns("user_id", "current", "withdrawn").
-		Values(userID, 0, 0).
-		PlaceholderFormat(squirrel.Dollar).
-		ToSql()
+	return applyListFilter(query, "uploaded_at", filter.ListFilter).ToSql()
 }
 
-func BuildUpdateUserBalanceQuery(userID uuid.NullUUID, current sql.NullInt64, withdrawn sql.NullInt64) (string, []interface{}, error) {
-	queryBuilder := squirrel.
-		Update("user_balance").
-		Where(squirrel.Eq{"user_id": userID}).
-		PlaceholderFormat(squirrel.Dollar)
-
-	if current.Valid {
-		queryBuilder = queryBuilder.Set("current", current.Int64)
-	}
+// BuildGetPendingOrdersQuery claims up to limit pending orders due for a poll
+// and moves their next poll backoffBase * 2^attempts seconds ahead, at most
+// backoffMax. SKIP LOCKED leaves rows another replica is claiming to it.
+func BuildGetPendingOrdersQuery(statuses []string, limit uint64, backoffBase float64, backoffMax float64) (string, []interface{}, error) {
+	due := squirrel.
+		Select("id").
+		From(`"user_order"`).
+		Where(squirrel.Eq{"status": statuses}).
+		Where("next_poll_at <= now()").
+		OrderBy("next_poll_at ASC", "id ASC").
+		Limit(limit).
+		Suffix("FOR UPDATE SKIP LOCKED")
 
-	if withdrawn.Valid {
-		queryBuilder = queryBuilder.Set("withdrawn", withdrawn.Int64)
-	}
+	query := squirrel.
+		Update(`"user_order"`).
+		Set("poll_attempts", squirrel.Expr("poll_attempts + 1")).
+		Set("next_poll_at", squirrel.Expr("now() + make_interval(secs => least(? * power(2, least(poll_attempts, 30)), ?))", backoffBase, backoffMax)).
+		Where(squirrel.Expr("id IN (?)", due)).
+		Suffix(returningUserOrder).
+		PlaceholderFormat(squirrel.Dollar)
 
-	return queryBuilder.ToSql()
+	return query.ToSql()
 }
 
-func BuildGetUserBalanceQuery(userID uuid.NullUUID) (string, []interface{}, error) {
+func BuildUpdateOrderAccrualQuery(order dao.UserOrderAccrualInputDAO, pendingStatuses []string) (string, []interface{}, error) {
 	query := squirrel.
-		Select("*").
-		From(`"user_balance"`).
-		Where(squirrel.Eq{"user_id": userID}).
+		Update(`"user_order"`).
+		Set("status", order.Status).
+		Set("accrual", order.Accrual).
+		Set("poll_attempts", 0).
+		Set("next_poll_at", squirrel.Expr("now()")).
+		Where(squirrel.And{
+			squirrel.Eq{"number": order.Number},
+			squirrel.Eq{"status": pendingStatuses},
+		}).
+		Suffix(returningUserOrder).
 		PlaceholderFormat(squirrel.Dollar)
 
 	return query.ToSql()
@@ -138,12 +180,52 @@ func BuildInsertWithdrawalQuery(withdrawal dao.UserWithdrawalInputDAO) (string,
 	return query.ToSql()
 }
 
-func BuildGetUserWithdrawalsQuery(userID uuid.NullUUID) (string, []interface{}, error) {
+func BuildGetUserWithdrawalsQuery(userID uuid.NullUUID, filter model.ListFilter) (string, []interface{}, error) {
 	query := squirrel.
 		Select("*").
 		From(`"user_withdrawal"`).
 		Where(squirrel.Eq{"user_id": userID}).
-		OrderBy("processed_at ASC").
 		PlaceholderFormat(squirrel.Dollar)
+
+	return applyListFilter(query, "processed_at", filter).ToSql()
+}
+
+// applyListFilter adds keyset pagination over (timeColumn, id) and the time
+// range of filter to query.
+func applyListFilter(query squirrel.SelectBuilder, timeColumn string, filter model.ListFilter) squirrel.SelectBuilder {
+	if filter.From != nil {
+		query = query.Where(squirrel.GtOrEq{timeColumn: *filter.From})
+	}
+	if filter.To != nil {
+		query = query.Where(squirrel.Lt{timeColumn: *filter.To})
+	}
+
+	direction, cmp := "ASC", ">"
+	if filter.Sort == model.SortDirectionEnumDesc {
+		direction, cmp = "DESC", "<"
+	}
+
+	if filter.After != nil {
+		query = query.Where(
+			fmt.Sprintf("(%s, id) %s (?, ?)", timeColumn, cmp),
+			filter.After.Time, filter.After.ID,
+		)
+	}
+
+	query = query.OrderBy(timeColumn+" "+direction, "id "+direction)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	return query
+}
+
+func BuildGetOrderBacklogQuery(statuses []string) (string, []interface{}, error) {
+	query := squirrel.
+		Select("count(*)", "min(uploaded_at)").
+		From(`"user_order"`).
+		Where(squirrel.Eq{"status": statuses}).
+		PlaceholderFormat(squirrel.Dollar)
+
 	return query.ToSql()
 }
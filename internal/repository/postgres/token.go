@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/repository/postgres/dao"
+	"github.com/FlyKarlik/gofemart/internal/repository/postgres/quries"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/google/uuid"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TokenRepo struct {
+	logger logger.Logger
+	c      *pgxpool.Pool
+	tx     *TxManager
+}
+
+func NewTokenRepo(logger logger.Logger, conn *pgxpool.Pool, txManager *TxManager) *TokenRepo {
+	return &TokenRepo{
+		logger: logger,
+		c:      conn,
+		tx:     txManager,
+	}
+}
+
+func (t *TokenRepo) CreateRefreshToken(ctx context.Context, input model.CreateRefreshTokenInput) (*model.RefreshToken, error) {
+	return t.createRefreshToken(ctx, conn(ctx, t.c), input)
+}
+
+func (t *TokenRepo) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	query, args, err := quries.BuildGetRefreshTokenByHashQuery(pghelpers.ToNullString(&tokenHash))
+	if err != nil {
+		t.logger.Error("postgres[token]", "GetRefreshTokenByHash", "Failed to build query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	var tokenDAO dao.RefreshTokenDAO
+	if err := scanRefreshToken(conn(ctx, t.c).QueryRow(ctx, query, args...), &tokenDAO); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			t.logger.Error("postgres[token]", "GetRefreshTokenByHash", "Failed to scan row", err)
+		}
+		return nil, pghelpers.WrapError(err)
+	}
+
+	return tokenDAO.ToModel(), nil
+}
+
+// RotateRefreshToken exchanges the active token identified by tokenHash for
+// next within one transaction. The new token inherits the user and family of
+// the old one. A token that is unknown, expired, revoked or already rotated
+// is reported as no rows.
+func (t *TokenRepo) RotateRefreshToken(ctx context.Context, tokenHash string, next model.CreateRefreshTokenInput) (*model.RefreshToken, error) {
+	var token *model.RefreshToken
+	err := t.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		token, err = t.rotateRefreshToken(ctx, tokenHash, next)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (t *TokenRepo) rotateRefreshToken(ctx context.Context, tokenHash string, next model.CreateRefreshTokenInput) (*model.RefreshToken, error) {
+	q := conn(ctx, t.c)
+
+	query, args, err := quries.BuildRotateRefreshTokenQuery(pghelpers.ToNullString(&tokenHash))
+	if err != nil {
+		t.logger.Error("postgres[token]", "RotateRefreshToken", "Failed to build rotate query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	var rotatedDAO dao.RefreshTokenDAO
+	if err := scanRefreshToken(q.QueryRow(ctx, query, args...), &rotatedDAO); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			t.logger.Error("postgres[token]", "RotateRefreshToken", "Failed to scan rotated token", err)
+		}
+		return nil, pghelpers.WrapError(err)
+	}
+
+	next.UserID = pghelpers.FromNullUUID(rotatedDAO.UserID)
+	next.FamilyID = pghelpers.FromNullUUID(rotatedDAO.FamilyID)
+
+	return t.createRefreshToken(ctx, q, next)
+}
+
+func (t *TokenRepo) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	query, args, err := quries.BuildRevokeRefreshTokenFamilyQuery(pghelpers.ToNullUUID(&familyID))
+	if err != nil {
+		t.logger.Error("postgres[token]", "RevokeRefreshTokenFamily", "Failed to build query", err)
+		return pghelpers.WrapError(err)
+	}
+
+	if _, err := conn(ctx, t.c).Exec(ctx, query, args...); err != nil {
+		t.logger.Error("postgres[token]", "RevokeRefreshTokenFamily", "Failed to revoke token family", err)
+		return pghelpers.WrapError(err)
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of the user, ending
+// all of their sessions.
+func (t *TokenRepo) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	query, args, err := quries.BuildRevokeUserRefreshTokensQuery(pghelpers.ToNullUUID(&userID))
+	if err != nil {
+		t.logger.Error("postgres[token]", "RevokeUserRefreshTokens", "Failed to build query", err)
+		return pghelpers.WrapError(err)
+	}
+
+	if _, err := conn(ctx, t.c).Exec(ctx, query, args...); err != nil {
+		t.logger.Error("postgres[token]", "RevokeUserRefreshTokens", "Failed to revoke user tokens", err)
+		return pghelpers.WrapError(err)
+	}
+
+	return nil
+}
+
+func (t *TokenRepo) createRefreshToken(ctx context.Context, q rowQuerier, input model.CreateRefreshTokenInput) (*model.RefreshToken, error) {
+	query, args, err := quries.BuildCreateRefreshTokenQuery(new(dao.CreateRefreshTokenInputDAO).FromModel(input))
+	if err != nil {
+		t.logger.Error("postgres[token]", "createRefreshToken", "Failed to build query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	var tokenDAO dao.RefreshTokenDAO
+	if err := scanRefreshToken(q.QueryRow(ctx, query, args...), &tokenDAO); err != nil {
+		t.logger.Error("postgres[token]", "createRefreshToken", "Failed to scan row", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	return tokenDAO.ToModel(), nil
+}
+
+func scanRefreshToken(row pgx.Row, tokenDAO *dao.RefreshTokenDAO) error {
+	return row.Scan(
+		&tokenDAO.ID,
+		&tokenDAO.UserID,
+		&tokenDAO.FamilyID,
+		&tokenDAO.TokenHash,
+		&tokenDAO.ExpiresAt,
+		&tokenDAO.CreatedAt,
+		&tokenDAO.RotatedAt,
+		&tokenDAO.RevokedAt,
+	)
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// rollbackTimeout bounds a rollback, which does not follow the
+// cancellation of the unit of work.
+const rollbackTimeout = 5 * time.Second
+
+type txKey struct{}
+
+// txState is what WithinTx puts into the context: the transaction and the
+// hooks to run once it is committed.
+type txState struct {
+	tx          pgx.Tx
+	afterCommit []func(ctx context.Context)
+}
+
+// querier is what repository methods run their statements on, the pool or
+// the transaction of the surrounding TxManager.WithinTx call.
+type querier interface {
+	rowQuerier
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// conn returns the transaction carried by ctx, or pool when there is none.
+func conn(ctx context.Context, pool *pgxpool.Pool) querier {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	return pool
+}
+
+// TxManager runs units of work spanning several repository calls in one
+// transaction.
+type TxManager struct {
+	logger        logger.Logger
+	c             *pgxpool.Pool
+	isoLevel      pgx.TxIsoLevel
+	retryAttempts int
+}
+
+func NewTxManager(logger logger.Logger, conn *pgxpool.Pool, isoLevel pgx.TxIsoLevel, retryAttempts int) *TxManager {
+	return &TxManager{
+		logger:        logger,
+		c:             conn,
+		isoLevel:      isoLevel,
+		retryAttempts: retryAttempts,
+	}
+}
+
+// WithinTx calls fn with a context carrying a new transaction, which every
+// repository method called with that context runs on. The transaction is
+// committed when fn returns nil and rolled back otherwise. Serialization
+// failures and deadlocks restart the whole transaction, so fn must be safe
+// to run again.
+//
+// Called with a context that already carries a transaction, WithinTx runs fn
+// in it and leaves commit, rollback and retries to the outermost call.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx)
+	}
+
+	return pghelpers.Retry(ctx, m.retryAttempts, func() error {
+		return m.withinTx(ctx, fn)
+	})
+}
+
+func (m *TxManager) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := m.c.BeginTx(ctx, pgx.TxOptions{IsoLevel: m.isoLevel})
+	if err != nil {
+		m.logger.Error("postgres[tx]", "WithinTx", "Failed to begin transaction", err)
+		return pghelpers.WrapError(err)
+	}
+	// A panic in fn must not leave the transaction open on a pooled
+	// connection, it is rolled back and the panic goes on.
+	defer func() {
+		if r := recover(); r != nil {
+			m.rollback(ctx, tx)
+			panic(r)
+		}
+		if err != nil {
+			m.rollback(ctx, tx)
+		}
+	}()
+
+	state := &txState{tx: tx}
+	if err = fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		m.logger.Error("postgres[tx]", "WithinTx", "Failed to commit transaction", err)
+		return pghelpers.WrapError(err)
+	}
+
+	for _, hook := range state.afterCommit {
+		hook(ctx)
+	}
+
+	return nil
+}
+
+// rollback runs even when ctx is done, the request that was cancelled is
+// what usually makes a transaction fail.
+func (m *TxManager) rollback(ctx context.Context, tx pgx.Tx) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	if err := tx.Rollback(ctx); err != nil {
+		m.logger.Error("postgres[tx]", "WithinTx", "Failed to rollback transaction", err)
+	}
+}
+
+// AfterCommit runs fn once the transaction carried by ctx is committed. A
+// transaction that is rolled back, or retried, drops the hooks registered
+// in it. Without a transaction in ctx fn runs right away.
+func (m *TxManager) AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
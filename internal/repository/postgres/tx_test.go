@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/FlyKarlik/gofemart/pkg/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TestWithinTxRollsBack leaves a unit of work with a withdrawal made in it.
+// The withdrawal must be undone and the connection given back to the pool,
+// also when fn panics or the context was cancelled on the way.
+func TestWithinTxRollsBack(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name      string
+		fn        func(cancel context.CancelFunc) error
+		wantPanic bool
+	}{
+		{
+			name: "error",
+			fn:   func(context.CancelFunc) error { return errFailed },
+		},
+		{
+			name: "error after cancel",
+			fn: func(cancel context.CancelFunc) error {
+				cancel()
+				return errFailed
+			},
+		},
+		{
+			name:      "panic",
+			fn:        func(context.CancelFunc) error { panic("unit of work failed") },
+			wantPanic: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestUserRepo(t, pgx.ReadCommitted)
+			userID := newFundedUser(t, repo, money.FromMinor(30000))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			panicked := func() (panicked bool) {
+				defer func() {
+					panicked = recover() != nil
+				}()
+				err := repo.tx.WithinTx(ctx, func(txCtx context.Context) error {
+					if err := withdraw(txCtx, repo, userID, money.FromMinor(10000)); err != nil {
+						t.Fatalf("withdraw in transaction: %v", err)
+					}
+					return tt.fn(cancel)
+				})
+				if !errors.Is(err, errFailed) {
+					t.Errorf("WithinTx error = %v, want %v", err, errFailed)
+				}
+				return false
+			}()
+
+			if panicked != tt.wantPanic {
+				t.Errorf("panicked = %v, want %v", panicked, tt.wantPanic)
+			}
+			if acquired := repo.c.Stat().AcquiredConns(); acquired != 0 {
+				t.Errorf("%d connections still acquired", acquired)
+			}
+			balance := currentBalance(t, repo, userID)
+			if got, want := balance.Current.Minor(), int64(30000); got != want {
+				t.Errorf("balance = %d, want %d", got, want)
+			}
+		})
+	}
+}
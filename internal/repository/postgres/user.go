@@ -8,6 +8,7 @@ import (
 	"github.com/FlyKarlik/gofemart/internal/repository/postgres/dao"
 	"github.com/FlyKarlik/gofemart/internal/repository/postgres/quries"
 	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
 	"github.com/FlyKarlik/gofemart/pkg/logger"
 	"github.com/google/uuid"
 
@@ -18,38 +19,27 @@ import (
 type UserRepo struct {
 	logger logger.Logger
 	c      *pgxpool.Pool
+	tx     *TxManager
 }
 
-func NewUserRepo(logger logger.Logger, conn *pgxpool.Pool) *UserRepo {
+func NewUserRepo(logger logger.Logger, conn *pgxpool.Pool, txManager *TxManager) *UserRepo {
 	return &UserRepo{
 		logger: logger,
 		c:      conn,
+		tx:     txManager,
 	}
 }
 
 func (u *UserRepo) CreateUser(ctx context.Context, input model.UserInput) (*model.User, error) {
 	userInputDAO := new(dao.UserInputDAO).FromModel(input)
 
-	tx, err := u.c.Begin(ctx)
-	if err != nil {
-		u.logger.Error("postgres[user]", "CreateUser", "Failed to begin transaction", err)
-		return nil, pghelpers.WrapError(err)
-	}
-	defer func() {
-		if err != nil {
-			if err := tx.Rollback(ctx); err != nil {
-				u.logger.Error("postgres[user]", "CreateUser", "Failed to rollback transaction", err)
-			}
-		}
-	}()
-
 	query, args, err := quries.BuildCreateUserQuery(userInputDAO)
 	if err != nil {
 		u.logger.Error("postgres[user]", "CreateUser", "Failed to build create user query", err)
 		return nil, pghelpers.WrapError(err)
 	}
 
-	row := tx.QueryRow(ctx, query, args...)
+	row := conn(ctx, u.c).QueryRow(ctx, query, args...)
 
 	var userDAO dao.UserDAO
 	if err := row.Scan(
@@ -62,22 +52,6 @@ func (u *UserRepo) CreateUser(ctx context.Context, input model.UserInput) (*mode
 		return nil, pghelpers.WrapError(err)
 	}
 
-	balanceQuery, balanceArgs, err := quries.BuildCreateUserBalanceQuery(userDAO.ID)
-	if err != nil {
-		u.logger.Error("postgres[user]", "CreateUser", "Failed to build create balance query", err)
-		return nil, pghelpers.WrapError(err)
-	}
-
-	if _, err := tx.Exec(ctx, balanceQuery, balanceArgs...); err != nil {
-		u.logger.Error("postgres[user]", "CreateUser", "Failed to exec balance insert", err)
-		return nil, pghelpers.WrapError(err)
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		u.logger.Error("postgres[user]", "CreateUser", "Failed to commit transaction", err)
-		return nil, pghelpers.WrapError(err)
-	}
-
 	return userDAO.ToModel(), nil
 }
 
@@ -88,7 +62,7 @@ func (u *UserRepo) GetUserByLogin(ctx context.Context, login string) (*model.Use
 		return nil, pghelpers.WrapError(err)
 	}
 
-	row := u.c.QueryRow(ctx, query, args...)
+	row := conn(ctx, u.c).QueryRow(ctx, query, args...)
 
 	var userDAO dao.UserDAO
 	if err := row.Scan(
@@ -111,7 +85,7 @@ func (u *UserRepo) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.Us
 		return nil, pghelpers.WrapError(err)
 	}
 
-	row := u.c.QueryRow(ctx, query, args...)
+	row := conn(ctx, u.c).QueryRow(ctx, query, args...)
 
 	var userDAO dao.UserDAO
 	if err := row.Scan(
@@ -127,6 +101,32 @@ func (u *UserRepo) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.Us
 	return userDAO.ToModel(), nil
 }
 
+// UpdateUserPassword replaces the password hash of the user with newHash
+// unless it is no longer oldHash, which is reported as no rows.
+func (u *UserRepo) UpdateUserPassword(ctx context.Context, userID uuid.UUID, oldHash string, newHash string) error {
+	query, args, err := quries.BuildUpdateUserPasswordQuery(
+		pghelpers.ToNullUUID(&userID),
+		pghelpers.ToNullString(&oldHash),
+		pghelpers.ToNullString(&newHash),
+	)
+	if err != nil {
+		u.logger.Error("postgres[user]", "UpdateUserPassword", "Failed to build update password query", err)
+		return pghelpers.WrapError(err)
+	}
+
+	tag, err := conn(ctx, u.c).Exec(ctx, query, args...)
+	if err != nil {
+		u.logger.Error("postgres[user]", "UpdateUserPassword", "Failed to update password", err)
+		return pghelpers.WrapError(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pghelpers.WrapError(pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
 func (u *UserRepo) CreateUserOrder(ctx context.Context, input model.UserOrderInput) (*model.UserOrder, error) {
 	userOrderInputDAO := new(dao.UserOrderInputDAO).FromModel(input)
 	query, args, err := quries.BuildCreateOrderQuery(userOrderInputDAO)
@@ -135,7 +135,7 @@ func (u *UserRepo) CreateUserOrder(ctx context.Context, input model.UserOrderInp
 		return nil, pghelpers.WrapError(err)
 	}
 
-	row := u.c.QueryRow(ctx, query, args...)
+	row := conn(ctx, u.c).QueryRow(ctx, query, args...)
 
 	var userOrderDAO dao.UserOrderDAO
 	if err := row.Scan(
@@ -153,14 +153,14 @@ func (u *UserRepo) CreateUserOrder(ctx context.Context, input model.UserOrderInp
 	return userOrderDAO.ToModel(), nil
 }
 
-func (u *UserRepo) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.UserOrder, error) {
-	query, args, err := quries.BuildGetUserOrdersQuery(pghelpers.ToNullUUID(&userID))
+func (u *UserRepo) GetUserOrders(ctx context.Context, userID uuid.UUID, filter model.OrderListFilter) ([]model.UserOrder, error) {
+	query, args, err := quries.BuildGetUserOrdersQuery(pghelpers.ToNullUUID(&userID), filter)
 	if err != nil {
 		u.logger.Error("postgres[user]", "GetUserOrdersByUserID", "Failed to build query get user orders by user id", err)
 		return nil, pghelpers.WrapError(err)
 	}
 
-	rows, err := u.c.Query(ctx, query, args...)
+	rows, err := conn(ctx, u.c).Query(ctx, query, args...)
 	if err != nil {
 		u.logger.Error("postgres[user]", "GetUserOrdersByUserID", "Failed to query user orders rows", err)
 		return nil, pghelpers.WrapError(err)
@@ -204,7 +204,7 @@ func (u *UserRepo) CheckUserOrderExists(ctx context.Context, number string, user
 	}
 
 	var exists int
-	err = u.c.QueryRow(ctx, query, args...).Scan(&exists)
+	err = conn(ctx, u.c).QueryRow(ctx, query, args...).Scan(&exists)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return false, nil
@@ -216,103 +216,195 @@ func (u *UserRepo) CheckUserOrderExists(ctx context.Context, number string, user
 	return true, nil
 }
 
-func (u *UserRepo) GetUserBalance(ctx context.Context, userID uuid.UUID) (*model.UserBalance[int64], error) {
-	query, args, err := quries.BuildGetUserBalanceQuery(pghelpers.ToNullUUID(&userID))
+func (u *UserRepo) GetPendingOrders(ctx context.Context, limit uint64, backoff model.OrderPollBackoff) ([]model.UserOrder, error) {
+	query, args, err := quries.BuildGetPendingOrdersQuery(pendingOrderStatuses(), limit, backoff.Base.Seconds(), backoff.Max.Seconds())
 	if err != nil {
-		u.logger.Error("postgres[user]", "GetUserBalance", "Failed to build query", err)
+		u.logger.Error("postgres[user]", "GetPendingOrders", "Failed to build query get pending orders", err)
 		return nil, pghelpers.WrapError(err)
 	}
 
-	var userBalanceDAO dao.UserBalanceDAO
-	row := u.c.QueryRow(ctx, query, args...)
-	if err := row.Scan(
-		&userBalanceDAO.UserID,
-		&userBalanceDAO.Current,
-		&userBalanceDAO.Withdrawn,
-	); err != nil {
-		u.logger.Error("postgres[user]", "GetUserBalance", "Query failed", err)
+	rows, err := conn(ctx, u.c).Query(ctx, query, args...)
+	if err != nil {
+		u.logger.Error("postgres[user]", "GetPendingOrders", "Failed to query pending orders rows", err)
 		return nil, pghelpers.WrapError(err)
 	}
+	defer rows.Close()
 
-	return userBalanceDAO.ToModel(), nil
+	var orders []model.UserOrder
+	for rows.Next() {
+		var order dao.UserOrderDAO
+		if err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Number,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+		); err != nil {
+			u.logger.Error("postgres[user]", "GetPendingOrders", "Failed to scan row", err)
+			return nil, pghelpers.WrapError(err)
+		}
+		orders = append(orders, *order.ToModel())
+	}
+
+	if err := rows.Err(); err != nil {
+		u.logger.Error("postgres[user]", "GetPendingOrders", "Rows error", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	return orders, nil
 }
 
-func (u *UserRepo) CreateUserWithdrawal(ctx context.Context, input model.UserWithdrawalInput[int64]) (*model.UserWithdrawal[int64], error) {
-	tx, err := u.c.Begin(ctx)
+func (u *UserRepo) GetOrderBacklog(ctx context.Context) (*model.OrderBacklog, error) {
+	query, args, err := quries.BuildGetOrderBacklogQuery(pendingOrderStatuses())
 	if err != nil {
-		u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to begin transaction", err)
+		u.logger.Error("postgres[user]", "GetOrderBacklog", "Failed to build query get order backlog", err)
 		return nil, pghelpers.WrapError(err)
 	}
 
-	defer func() {
-		if err != nil {
-			if err := tx.Rollback(ctx); err != nil {
-				u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to rollback transaction", err)
-			}
-		}
-	}()
+	var backlog model.OrderBacklog
+	if err := conn(ctx, u.c).QueryRow(ctx, query, args...).Scan(&backlog.Pending, &backlog.OldestUploadedAt); err != nil {
+		u.logger.Error("postgres[user]", "GetOrderBacklog", "Failed to query order backlog", err)
+		return nil, pghelpers.WrapError(err)
+	}
 
-	withdrawalDAO := new(dao.UserWithdrawalInputDAO).FromModel(input)
+	return &backlog, nil
+}
 
-	query, args, err := quries.BuildInsertWithdrawalQuery(withdrawalDAO)
+// UpdateUserOrderAccrual moves a pending order to the given status and, when
+// the order is processed, credits its accrual to the owner's balance within
+// the same transaction. Orders that already reached a final status are left
+// untouched and reported as no rows.
+func (u *UserRepo) UpdateUserOrderAccrual(ctx context.Context, input model.UserOrderAccrualInput) (*model.UserOrder, error) {
+	var order *model.UserOrder
+	err := u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = u.updateUserOrderAccrual(ctx, input)
+		return err
+	})
 	if err != nil {
-		u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to build insert query", err)
-		return nil, pghelpers.WrapError(err)
+		return nil, err
 	}
+	return order, nil
+}
 
-	row := tx.QueryRow(ctx, query, args...)
+func (u *UserRepo) updateUserOrderAccrual(ctx context.Context, input model.UserOrderAccrualInput) (*model.UserOrder, error) {
+	q := conn(ctx, u.c)
+	orderDAO := new(dao.UserOrderAccrualInputDAO).FromModel(input)
 
-	var resultDAO dao.UserWithdrawalDAO
-	if err := row.Scan(
+	query, args, err := quries.BuildUpdateOrderAccrualQuery(orderDAO, pendingOrderStatuses())
+	if err != nil {
+		u.logger.Error("postgres[user]", "UpdateUserOrderAccrual", "Failed to build update order query", err)
+		return nil, pghelpers.WrapError(err)
+	}
+
+	var resultDAO dao.UserOrderDAO
+	if err := q.QueryRow(ctx, query, args...).Scan(
 		&resultDAO.ID,
 		&resultDAO.UserID,
-		&resultDAO.OrderNumber,
-		&resultDAO.Sum,
-		&resultDAO.ProcessedAt,
+		&resultDAO.Number,
+		&resultDAO.Status,
+		&resultDAO.Accrual,
+		&resultDAO.UploadedAt,
 	); err != nil {
-		u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to scan withdrawal result", err)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			u.logger.Error("postgres[user]", "UpdateUserOrderAccrual", "Failed to scan order row", err)
+		}
 		return nil, pghelpers.WrapError(err)
 	}
 
-	updateQuery, updateArgs, err := quries.BuildUpdateUserBalanceQuery(
-		pghelpers.ToNullUUID(input.UserID),
-		pghelpers.ToNullInt64(input.CurrentBalance),
-		pghelpers.ToNullInt64(input.WitdrawnBalance),
-	)
+	if resultDAO.Status.String == model.OrderStatusEnumProcessed.String() && resultDAO.Accrual.Int64 > 0 {
+		if _, err := u.appendLedgerEntry(ctx, q, model.LedgerEntryInput{
+			UserID:        pghelpers.FromNullUUID(resultDAO.UserID),
+			EntryType:     generics.Pointer(model.LedgerEntryTypeEnumAccrual),
+			DebitAccount:  generics.Pointer(model.LedgerAccountEnumAccrual),
+			CreditAccount: generics.Pointer(model.LedgerAccountEnumUser),
+			Amount:        pghelpers.FromNullMoney(resultDAO.Accrual),
+			OrderNumber:   pghelpers.FromNullString(resultDAO.Number),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return resultDAO.ToModel(), nil
+}
+
+func (u *UserRepo) GetUserBalance(ctx context.Context, userID uuid.UUID) (*model.UserBalance, error) {
+	userBalanceDAO, err := u.getUserBalance(ctx, conn(ctx, u.c), pghelpers.ToNullUUID(&userID))
 	if err != nil {
-		u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to build update balance query", err)
-		return nil, pghelpers.WrapError(err)
+		return nil, err
+	}
+
+	return userBalanceDAO.ToModel(), nil
+}
+
+// CreateUserWithdrawal posts the debit to the ledger and records the
+// withdrawal in one transaction. A sum the balance does not cover is rejected
+// by the ledger with a check violation.
+func (u *UserRepo) CreateUserWithdrawal(ctx context.Context, input model.UserWithdrawalInput) (*model.UserWithdrawal, error) {
+	var withdrawal *model.UserWithdrawal
+	err := u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		withdrawal, err = u.createUserWithdrawal(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+func (u *UserRepo) createUserWithdrawal(ctx context.Context, input model.UserWithdrawalInput) (*model.UserWithdrawal, error) {
+	q := conn(ctx, u.c)
+	withdrawalDAO := new(dao.UserWithdrawalInputDAO).FromModel(input)
+
+	if _, err := u.appendLedgerEntry(ctx, q, model.LedgerEntryInput{
+		UserID:        input.UserID,
+		EntryType:     generics.Pointer(model.LedgerEntryTypeEnumWithdrawal),
+		DebitAccount:  generics.Pointer(model.LedgerAccountEnumUser),
+		CreditAccount: generics.Pointer(model.LedgerAccountEnumWithdrawal),
+		Amount:        input.Sum,
+		OrderNumber:   input.OrderNumber,
+	}); err != nil {
+		return nil, err
 	}
 
-	_, err = tx.Exec(ctx, updateQuery, updateArgs...)
+	query, args, err := quries.BuildInsertWithdrawalQuery(withdrawalDAO)
 	if err != nil {
-		u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to update user balance", err)
+		u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to build insert query", err)
 		return nil, pghelpers.WrapError(err)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to commit transaction", err)
+	var resultDAO dao.UserWithdrawalDAO
+	if err := q.QueryRow(ctx, query, args...).Scan(
+		&resultDAO.ID,
+		&resultDAO.UserID,
+		&resultDAO.OrderNumber,
+		&resultDAO.Sum,
+		&resultDAO.ProcessedAt,
+	); err != nil {
+		u.logger.Error("postgres[user]", "CreateUserWithdrawal", "Failed to scan withdrawal result", err)
 		return nil, pghelpers.WrapError(err)
 	}
 
 	return resultDAO.ToModel(), nil
 }
 
-func (u *UserRepo) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.UserWithdrawal[int64], error) {
-	query, args, err := quries.BuildGetUserWithdrawalsQuery(pghelpers.ToNullUUID(&userID))
+func (u *UserRepo) GetUserWithdrawals(ctx context.Context, userID uuid.UUID, filter model.ListFilter) ([]model.UserWithdrawal, error) {
+	query, args, err := quries.BuildGetUserWithdrawalsQuery(pghelpers.ToNullUUID(&userID), filter)
 	if err != nil {
 		u.logger.Error("postgres[user]", "GetUserWithdrawals", "Failed to build query", err)
 		return nil, pghelpers.WrapError(err)
 	}
 
-	rows, err := u.c.Query(ctx, query, args...)
+	rows, err := conn(ctx, u.c).Query(ctx, query, args...)
 	if err != nil {
 		u.logger.Error("postgres[user]", "GetUserWithdrawals", "Failed to execute query", err)
 		return nil, pghelpers.WrapError(err)
 	}
 	defer rows.Close()
 
-	var withdrawals []model.UserWithdrawal[int64]
+	var withdrawals []model.UserWithdrawal
 	for rows.Next() {
 		var w dao.UserWithdrawalDAO
 		if err := rows.Scan(
@@ -335,3 +427,10 @@ func (u *UserRepo) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]
 
 	return withdrawals, nil
 }
+
+func pendingOrderStatuses() []string {
+	return []string{
+		model.OrderStatusEnumNew.String(),
+		model.OrderStatusEnumProcessing.String(),
+	}
+}
@@ -0,0 +1,271 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/pgtest"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+	"github.com/google/uuid"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func newTestUserRepo(t *testing.T, isoLevel pgx.TxIsoLevel) *UserRepo {
+	t.Helper()
+
+	pool := pgtest.Pool(t)
+	log, err := logger.New("error")
+	if err != nil {
+		t.Fatalf("create logger: %v", err)
+	}
+	return NewUserRepo(log, pool, NewTxManager(log, pool, isoLevel, 3))
+}
+
+// newFundedUser creates a user and credits it with balance through a
+// processed order.
+func newFundedUser(t *testing.T, repo *UserRepo, balance money.Amount) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+
+	user, err := repo.CreateUser(ctx, model.UserInput{
+		Login:    generics.Pointer("user-" + uuid.NewString()),
+		Password: generics.Pointer("hash"),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	number := pgtest.OrderNumber()
+	if _, err := repo.CreateUserOrder(ctx, model.UserOrderInput{
+		UserID: user.ID,
+		Number: &number,
+		Status: generics.Pointer(model.OrderStatusEnumNew),
+	}); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	if _, err := repo.UpdateUserOrderAccrual(ctx, model.UserOrderAccrualInput{
+		Number:  &number,
+		Status:  generics.Pointer(model.OrderStatusEnumProcessed),
+		Accrual: &balance,
+	}); err != nil {
+		t.Fatalf("credit accrual: %v", err)
+	}
+
+	return *user.ID
+}
+
+func withdraw(ctx context.Context, repo *UserRepo, userID uuid.UUID, sum money.Amount) error {
+	_, err := repo.CreateUserWithdrawal(ctx, model.UserWithdrawalInput{
+		UserID:      &userID,
+		OrderNumber: generics.Pointer(pgtest.OrderNumber()),
+		Sum:         &sum,
+	})
+	return err
+}
+
+func currentBalance(t *testing.T, repo *UserRepo, userID uuid.UUID) *model.UserBalance {
+	t.Helper()
+
+	balance, err := repo.GetUserBalance(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("get balance: %v", err)
+	}
+	return balance
+}
+
+// TestCreateUserWithdrawalConcurrent races more withdrawals than the balance
+// covers. Under read committed the user row lock lets exactly the affordable
+// ones through. Under serializable some may also run out of retries, but
+// none may overdraw.
+func TestCreateUserWithdrawalConcurrent(t *testing.T) {
+	for _, isoLevel := range []pgx.TxIsoLevel{pgx.ReadCommitted, pgx.Serializable} {
+		t.Run(string(isoLevel), func(t *testing.T) {
+			repo := newTestUserRepo(t, isoLevel)
+
+			const (
+				workers    = 20
+				sum        = 10000
+				affordable = 5
+			)
+			userID := newFundedUser(t, repo, money.FromMinor(affordable*sum))
+
+			var (
+				wg         sync.WaitGroup
+				mu         sync.Mutex
+				succeeded  int
+				rejected   int
+				serialized int
+				unexpect   []error
+			)
+			start := make(chan struct{})
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+
+					err := withdraw(context.Background(), repo, userID, money.FromMinor(sum))
+
+					mu.Lock()
+					defer mu.Unlock()
+					var pgErr *pghelpers.PgError
+					switch {
+					case err == nil:
+						succeeded++
+					case errors.As(err, &pgErr) && pgErr.Code == pghelpers.ErrCheckViolation:
+						rejected++
+					case pghelpers.IsRetryable(err):
+						serialized++
+					default:
+						unexpect = append(unexpect, err)
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			for _, err := range unexpect {
+				t.Errorf("unexpected withdrawal error: %v", err)
+			}
+			switch {
+			case isoLevel == pgx.ReadCommitted && succeeded != affordable:
+				t.Errorf("succeeded withdrawals = %d, want %d", succeeded, affordable)
+			case isoLevel == pgx.ReadCommitted && serialized != 0:
+				t.Errorf("serialization failures = %d, want 0", serialized)
+			case succeeded < 1 || succeeded > affordable:
+				t.Errorf("succeeded withdrawals = %d, want 1..%d", succeeded, affordable)
+			}
+			if total := succeeded + rejected + serialized + len(unexpect); total != workers {
+				t.Errorf("accounted withdrawals = %d, want %d", total, workers)
+			}
+
+			balance := currentBalance(t, repo, userID)
+			if balance.Current.Minor() < 0 {
+				t.Fatalf("balance went negative: %s", balance.Current)
+			}
+			if got, want := balance.Current.Minor(), int64(affordable-succeeded)*sum; got != want {
+				t.Errorf("balance = %d, want %d", got, want)
+			}
+			if got, want := balance.Withdrawn.Minor(), int64(succeeded)*sum; got != want {
+				t.Errorf("withdrawn = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+// TestCreateUserWithdrawalSerializationRetry commits a withdrawal between
+// the balance read and the withdrawal of a serializable transaction, which
+// has to fail with a serialization error and be run again by WithinTx.
+func TestCreateUserWithdrawalSerializationRetry(t *testing.T) {
+	repo := newTestUserRepo(t, pgx.Serializable)
+	userID := newFundedUser(t, repo, money.FromMinor(30000))
+
+	var attempts int
+	err := repo.tx.WithinTx(context.Background(), func(txCtx context.Context) error {
+		attempts++
+
+		if _, err := repo.GetUserBalance(txCtx, userID); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			if err := withdraw(context.Background(), repo, userID, money.FromMinor(10000)); err != nil {
+				t.Fatalf("concurrent withdrawal: %v", err)
+			}
+		}
+		return withdraw(txCtx, repo, userID, money.FromMinor(10000))
+	})
+	if err != nil {
+		t.Fatalf("withdraw in transaction: %v", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+	balance := currentBalance(t, repo, userID)
+	if got, want := balance.Current.Minor(), int64(10000); got != want {
+		t.Errorf("balance = %d, want %d", got, want)
+	}
+	if got, want := balance.Withdrawn.Minor(), int64(20000); got != want {
+		t.Errorf("withdrawn = %d, want %d", got, want)
+	}
+}
+
+// TestGetPendingOrdersClaims claims from two pollers at once. Every due order
+// goes to exactly one of them and is not due again until its backoff passed.
+func TestGetPendingOrdersClaims(t *testing.T) {
+	repo := newTestUserRepo(t, pgx.ReadCommitted)
+	ctx := context.Background()
+
+	user, err := repo.CreateUser(ctx, model.UserInput{
+		Login:    generics.Pointer("user-" + uuid.NewString()),
+		Password: generics.Pointer("hash"),
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	const orders = 10
+	numbers := make(map[string]bool, orders)
+	for range orders {
+		number := pgtest.OrderNumber()
+		if _, err := repo.CreateUserOrder(ctx, model.UserOrderInput{
+			UserID: user.ID,
+			Number: &number,
+			Status: generics.Pointer(model.OrderStatusEnumNew),
+		}); err != nil {
+			t.Fatalf("create order: %v", err)
+		}
+		numbers[number] = true
+	}
+
+	backoff := model.OrderPollBackoff{Base: time.Hour, Max: time.Hour}
+	claim := func() map[string]int {
+		claimed, err := repo.GetPendingOrders(ctx, 1000, backoff)
+		if err != nil {
+			t.Fatalf("claim pending orders: %v", err)
+		}
+		counts := make(map[string]int)
+		for _, order := range claimed {
+			if numbers[*order.Number] {
+				counts[*order.Number]++
+			}
+		}
+		return counts
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		totals = make(map[string]int)
+	)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts := claim()
+			mu.Lock()
+			defer mu.Unlock()
+			for number, n := range counts {
+				totals[number] += n
+			}
+		}()
+	}
+	wg.Wait()
+
+	for number := range numbers {
+		if totals[number] != 1 {
+			t.Errorf("order %s claimed %d times, want 1", number, totals[number])
+		}
+	}
+	if again := claim(); len(again) != 0 {
+		t.Errorf("%d orders claimed again before their backoff passed", len(again))
+	}
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	"github.com/FlyKarlik/gofemart/config"
 	"github.com/FlyKarlik/gofemart/internal/model"
 	"github.com/FlyKarlik/gofemart/internal/repository/cache"
 	"github.com/FlyKarlik/gofemart/internal/repository/postgres"
@@ -11,6 +13,7 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -18,30 +21,127 @@ type IUserRepository interface {
 	CreateUser(ctx context.Context, input model.UserInput) (*model.User, error)
 	GetUserByLogin(ctx context.Context, login string) (*model.User, error)
 	GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error)
+	UpdateUserPassword(ctx context.Context, userID uuid.UUID, oldHash string, newHash string) error
 
 	CreateUserOrder(ctx context.Context, input model.UserOrderInput) (*model.UserOrder, error)
-	GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.UserOrder, error)
+	GetUserOrders(ctx context.Context, userID uuid.UUID, filter model.OrderListFilter) ([]model.UserOrder, error)
 	CheckUserOrderExists(ctx context.Context, number string, userID uuid.UUID) (bool, error)
+	// GetPendingOrders claims up to limit pending orders that are due for a
+	// poll, earliest first, and schedules their next poll by backoff. An
+	// order claimed by one caller is not returned to another until then.
+	GetPendingOrders(ctx context.Context, limit uint64, backoff model.OrderPollBackoff) ([]model.UserOrder, error)
+	GetOrderBacklog(ctx context.Context) (*model.OrderBacklog, error)
+	UpdateUserOrderAccrual(ctx context.Context, input model.UserOrderAccrualInput) (*model.UserOrder, error)
 
-	GetUserBalance(ctx context.Context, userID uuid.UUID) (*model.UserBalance[int64], error)
-	CreateUserWithdrawal(ctx context.Context, input model.UserWithdrawalInput[int64]) (*model.UserWithdrawal[int64], error)
-	GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.UserWithdrawal[int64], error)
+	GetUserBalance(ctx context.Context, userID uuid.UUID) (*model.UserBalance, error)
+	CreateUserWithdrawal(ctx context.Context, input model.UserWithdrawalInput) (*model.UserWithdrawal, error)
+	GetUserWithdrawals(ctx context.Context, userID uuid.UUID, filter model.ListFilter) ([]model.UserWithdrawal, error)
+	GetUserLedger(ctx context.Context, userID uuid.UUID) ([]model.LedgerEntry, error)
+}
+
+type ITokenRepository interface {
+	CreateRefreshToken(ctx context.Context, input model.CreateRefreshTokenInput) (*model.RefreshToken, error)
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, tokenHash string, next model.CreateRefreshTokenInput) (*model.RefreshToken, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
 }
 
 type IUserCache interface {
 	Set(ctx context.Context, userID uuid.UUID, user *model.User, ttl time.Duration) error
 	Get(ctx context.Context, userID uuid.UUID) (*model.User, bool, error)
 	Delete(ctx context.Context, userID uuid.UUID) error
+	// GetOrLoad returns the cached user or the one load returns, which is
+	// then cached for ttl.
+	GetOrLoad(ctx context.Context, userID uuid.UUID, ttl time.Duration, load func(ctx context.Context, userID uuid.UUID) (*model.User, error)) (*model.User, error)
+}
+
+// IBalanceCache caches balances. Invalidate must be called once a change to
+// the balance is committed, see ITxManager.AfterCommit.
+type IBalanceCache interface {
+	GetOrLoad(ctx context.Context, userID uuid.UUID, ttl time.Duration, load func(ctx context.Context, userID uuid.UUID) (*model.UserBalance, error)) (*model.UserBalance, error)
+	Invalidate(ctx context.Context, userID uuid.UUID) error
+}
+
+type IIdempotencyCache interface {
+	Reserve(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*model.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, key string, record *model.IdempotencyRecord, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+type ITokenDenylist interface {
+	Add(ctx context.Context, tokenID string, ttl time.Duration) error
+	Contains(ctx context.Context, tokenID string) (bool, error)
+	RevokeUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time, ttl time.Duration) error
+	UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error)
+}
+
+type ILoginAttemptCache interface {
+	GetLockout(ctx context.Context, key string) (time.Duration, error)
+	SetLockout(ctx context.Context, key string, d time.Duration) error
+	RegisterFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	ResetFailures(ctx context.Context, key string) error
+}
+
+type IOrderEventBus interface {
+	Publish(ctx context.Context, event model.OrderEvent) error
+	Subscribe(ctx context.Context, userID uuid.UUID) (<-chan model.OrderEvent, func() error, error)
+}
+
+// ITxManager runs fn in one transaction together with every repository call
+// fn makes with the context it is given.
+type ITxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// AfterCommit defers fn until the transaction in ctx is committed and
+	// never runs it if the transaction is rolled back.
+	AfterCommit(ctx context.Context, fn func(ctx context.Context))
 }
 
 type Repository struct {
+	ITxManager
 	IUserRepository
+	ITokenRepository
 	IUserCache
+	IBalanceCache
+	IIdempotencyCache
+	ITokenDenylist
+	ILoginAttemptCache
+	IOrderEventBus
 }
 
-func New(logger logger.Logger, conn *pgxpool.Pool, redisClient *redis.Client) *Repository {
+func New(cfg *config.Config, logger logger.Logger, conn *pgxpool.Pool, redisClient *redis.Client) *Repository {
+	txManager := postgres.NewTxManager(
+		logger,
+		conn,
+		pgx.TxIsoLevel(strings.ReplaceAll(cfg.Infra.Postgres.TxIsolationLevel, "_", " ")),
+		cfg.Infra.Postgres.TxRetryAttempts,
+	)
+
+	userCacheCfg := cache.UserCacheConfig{
+		Namespace:        cfg.Infra.Redis.CacheNamespace,
+		Codec:            cache.NewCodec(cfg.Infra.Redis.CacheCodec),
+		L1Size:           cfg.AppGofemart.UserCacheL1Size,
+		L1TTL:            cfg.AppGofemart.UserCacheL1TTL,
+		StaleTTL:         cfg.AppGofemart.UserCacheStaleTTL,
+		BreakerThreshold: cfg.Infra.Redis.BreakerThreshold,
+		BreakerCooldown:  cfg.Infra.Redis.BreakerCooldown,
+	}
+	balanceCacheCfg := cache.BalanceCacheConfig{
+		Namespace:        userCacheCfg.Namespace,
+		Codec:            userCacheCfg.Codec,
+		BreakerThreshold: cfg.Infra.Redis.BreakerThreshold,
+		BreakerCooldown:  cfg.Infra.Redis.BreakerCooldown,
+	}
+
 	return &Repository{
-		IUserRepository: postgres.NewUserRepo(logger, conn),
-		IUserCache:      cache.NewUserCache(logger, redisClient),
+		ITxManager:         txManager,
+		IUserRepository:    postgres.NewUserRepo(logger, conn, txManager),
+		ITokenRepository:   postgres.NewTokenRepo(logger, conn, txManager),
+		IUserCache:         cache.NewUserCache(logger, redisClient, userCacheCfg),
+		IBalanceCache:      cache.NewBalanceCache(logger, redisClient, balanceCacheCfg),
+		IIdempotencyCache:  cache.NewIdempotencyCache(logger, redisClient, cfg.Infra.Redis.CacheNamespace),
+		ITokenDenylist:     cache.NewTokenDenylist(logger, redisClient),
+		ILoginAttemptCache: cache.NewLoginAttemptCache(logger, redisClient),
+		IOrderEventBus:     cache.NewOrderEventBus(logger, redisClient),
 	}
 }
@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/metrics"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/pkg/accrual"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+)
+
+type IAccrualClient interface {
+	GetOrderAccrual(ctx context.Context, number string) (*accrual.OrderAccrual, error)
+}
+
+type accrualUsecase struct {
+	cfg           *config.Config
+	logger        logger.Logger
+	txManager     repository.ITxManager
+	userRepo      repository.IUserRepository
+	balances      *balanceCache
+	orderEvents   repository.IOrderEventBus
+	accrualClient IAccrualClient
+}
+
+func newAccrualUsecase(cfg *config.Config, logger logger.Logger, txManager repository.ITxManager, userRepo repository.IUserRepository, balances *balanceCache, orderEvents repository.IOrderEventBus, accrualClient IAccrualClient) *accrualUsecase {
+	return &accrualUsecase{
+		cfg:           cfg,
+		logger:        logger,
+		txManager:     txManager,
+		userRepo:      userRepo,
+		balances:      balances,
+		orderEvents:   orderEvents,
+		accrualClient: accrualClient,
+	}
+}
+
+// GetPendingOrders claims the next batch of orders due for a poll. Each one
+// is not handed out again before its backoff has passed.
+func (u *accrualUsecase) GetPendingOrders(ctx context.Context, limit uint64) ([]model.UserOrder, error) {
+	orders, err := u.userRepo.GetPendingOrders(ctx, limit, model.OrderPollBackoff{
+		Base: u.cfg.AppGofemart.AccrualBackoffBase,
+		Max:  u.cfg.AppGofemart.AccrualBackoffMax,
+	})
+	if err != nil {
+		u.logger.Error("usecase[accrual]", "GetPendingOrders", "Failed to get pending orders", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumGetPendingOrders, err)
+	}
+	return orders, nil
+}
+
+func (u *accrualUsecase) GetOrderBacklog(ctx context.Context) (*model.OrderBacklog, error) {
+	backlog, err := u.userRepo.GetOrderBacklog(ctx)
+	if err != nil {
+		u.logger.Error("usecase[accrual]", "GetOrderBacklog", "Failed to get order backlog", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumGetOrderBacklog, err)
+	}
+	return backlog, nil
+}
+
+func (u *accrualUsecase) ProcessOrderAccrual(ctx context.Context, order model.UserOrder) error {
+	orderAccrual, err := u.accrualClient.GetOrderAccrual(ctx, *order.Number)
+	if err != nil {
+		if errors.Is(err, accrual.ErrOrderNotRegistered) {
+			u.logger.Debug("usecase[accrual]", "ProcessOrderAccrual", "Order is not registered in accrual system yet", *order.Number)
+			return nil
+		}
+		if errors.Is(err, accrual.ErrTooManyRequests) {
+			u.logger.Warn("usecase[accrual]", "ProcessOrderAccrual", "Accrual system is rate limiting requests", err, *order.Number)
+			return nil
+		}
+		u.logger.Error("usecase[accrual]", "ProcessOrderAccrual", "Failed to get order accrual", err)
+		return err
+	}
+
+	status := orderStatusFromAccrual(orderAccrual.Status)
+	if status == model.OrderStatusEnumProcessing && order.Status != nil && *order.Status == status {
+		return nil
+	}
+
+	input := model.UserOrderAccrualInput{
+		Number: order.Number,
+		Status: &status,
+	}
+	if status == model.OrderStatusEnumProcessed {
+		input.Accrual = orderAccrual.Accrual
+		if input.Accrual == nil {
+			input.Accrual = generics.Pointer(money.FromMinor(0))
+		}
+	}
+
+	var updated *model.UserOrder
+	err = u.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		updated, err = u.userRepo.UpdateUserOrderAccrual(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		if updated.Accrual != nil && updated.Accrual.Minor() > 0 {
+			u.balances.invalidateAfterCommit(ctx, *updated.UserID)
+		}
+		return nil
+	})
+	if err != nil {
+		var pgErr *pghelpers.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pghelpers.ErrNoRows {
+			u.logger.Debug("usecase[accrual]", "ProcessOrderAccrual", "Order already reached final status", *order.Number)
+			return nil
+		}
+		u.logger.Error("usecase[accrual]", "ProcessOrderAccrual", "Failed to update order accrual", err)
+		return wrapUsecaseError(model.EventTypeEnumProcessOrderAccrual, err)
+	}
+
+	metrics.OrdersProcessed.WithLabelValues(updated.Status.String()).Inc()
+	if updated.Accrual != nil {
+		metrics.PointsAccrued.Add(float64(updated.Accrual.Minor()) / money.Scale)
+	}
+
+	// Subscribers only miss a live update when publishing fails, the order
+	// itself is already saved.
+	if err := u.orderEvents.Publish(ctx, model.OrderEvent{
+		UserID:    updated.UserID,
+		Number:    updated.Number,
+		Status:    updated.Status,
+		Accrual:   updated.Accrual,
+		UpdatedAt: time.Now(),
+	}); err != nil {
+		u.logger.Warn("usecase[accrual]", "ProcessOrderAccrual", "Failed to publish order event", err, *order.Number)
+	}
+
+	return nil
+}
+
+func orderStatusFromAccrual(status accrual.OrderStatus) model.OrderStatusEnum {
+	switch status {
+	case accrual.OrderStatusProcessed:
+		return model.OrderStatusEnumProcessed
+	case accrual.OrderStatusInvalid:
+		return model.OrderStatusEnumInvalid
+	default:
+		return model.OrderStatusEnumProcessing
+	}
+}
@@ -0,0 +1,104 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/accrual"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+)
+
+func TestProcessOrderAccrual(t *testing.T) {
+	const number = "12345678903"
+
+	tests := []struct {
+		name        string
+		registered  bool
+		status      accrual.OrderStatus
+		amount      *int64
+		wantStatus  model.OrderStatusEnum
+		wantBalance int64
+	}{
+		{name: "not registered yet", registered: false, wantStatus: model.OrderStatusEnumNew},
+		{name: "registered", registered: true, status: accrual.OrderStatusRegistered, wantStatus: model.OrderStatusEnumProcessing},
+		{name: "processing", registered: true, status: accrual.OrderStatusProcessing, wantStatus: model.OrderStatusEnumProcessing},
+		{name: "invalid", registered: true, status: accrual.OrderStatusInvalid, wantStatus: model.OrderStatusEnumInvalid},
+		{name: "processed with accrual", registered: true, status: accrual.OrderStatusProcessed, amount: generics.Pointer(int64(72950)), wantStatus: model.OrderStatusEnumProcessed, wantBalance: 72950},
+		{name: "processed without accrual", registered: true, status: accrual.OrderStatusProcessed, wantStatus: model.OrderStatusEnumProcessed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := env.registerUser(t, "user")
+
+			if err := env.usecase.CreateUserOrder(ctx, model.UserOrderInput{Number: generics.Pointer(number)}); err != nil {
+				t.Fatalf("upload order: %v", err)
+			}
+			if tt.registered {
+				env.accrual.set(number, tt.status, tt.amount)
+			}
+
+			orders, err := env.usecase.GetPendingOrders(context.Background(), 100)
+			if err != nil {
+				t.Fatalf("get pending orders: %v", err)
+			}
+			// A second run must neither fail nor credit the order twice.
+			for range 2 {
+				for _, order := range orders {
+					if err := env.usecase.ProcessOrderAccrual(context.Background(), order); err != nil {
+						t.Fatalf("process order: %v", err)
+					}
+				}
+			}
+
+			orders, err = env.repo.GetUserOrders(context.Background(), userID(ctx), model.OrderListFilter{})
+			if err != nil {
+				t.Fatalf("get orders: %v", err)
+			}
+			if len(orders) != 1 || *orders[0].Status != tt.wantStatus {
+				t.Fatalf("orders = %v, want one %s order", orders, tt.wantStatus)
+			}
+
+			if current, _ := env.balance(t, ctx); current != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", current, tt.wantBalance)
+			}
+		})
+	}
+}
+
+// TestGetPendingOrdersBackoff checks that orders the accrual system keeps
+// answering the same for are not handed out again right away, so they do
+// not hold up newer orders.
+func TestGetPendingOrdersBackoff(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.registerUser(t, "user")
+
+	numbers := []string{"12345678903", "79927398713"}
+	for _, number := range numbers {
+		if err := env.usecase.CreateUserOrder(ctx, model.UserOrderInput{Number: generics.Pointer(number)}); err != nil {
+			t.Fatalf("upload order %s: %v", number, err)
+		}
+	}
+
+	// Neither order is registered in the accrual system, each poll leaves
+	// them pending.
+	for _, want := range append(numbers, "") {
+		orders, err := env.usecase.GetPendingOrders(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("get pending orders: %v", err)
+		}
+
+		var got string
+		if len(orders) > 0 {
+			got = *orders[0].Number
+			if err := env.usecase.ProcessOrderAccrual(context.Background(), orders[0]); err != nil {
+				t.Fatalf("process order %s: %v", got, err)
+			}
+		}
+		if got != want {
+			t.Fatalf("claimed order %q, want %q", got, want)
+		}
+	}
+}
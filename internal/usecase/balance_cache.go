@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// balanceCache serves balances from the cache and drops them once a change
+// to them is committed. A rolled back change never touches the cache.
+type balanceCache struct {
+	cfg       *config.Config
+	logger    logger.Logger
+	txManager repository.ITxManager
+	userRepo  repository.IUserRepository
+	balances  repository.IBalanceCache
+}
+
+func newBalanceCache(cfg *config.Config, logger logger.Logger, txManager repository.ITxManager, userRepo repository.IUserRepository, balances repository.IBalanceCache) *balanceCache {
+	return &balanceCache{
+		cfg:       cfg,
+		logger:    logger,
+		txManager: txManager,
+		userRepo:  userRepo,
+		balances:  balances,
+	}
+}
+
+func (b *balanceCache) get(ctx context.Context, userID uuid.UUID) (*model.UserBalance, error) {
+	return b.balances.GetOrLoad(ctx, userID, b.cfg.AppGofemart.BalanceCacheTTL, b.userRepo.GetUserBalance)
+}
+
+// invalidateAfterCommit drops the cached balance of userID when the
+// transaction in ctx commits, or right away when there is none.
+func (b *balanceCache) invalidateAfterCommit(ctx context.Context, userID uuid.UUID) {
+	b.txManager.AfterCommit(ctx, func(ctx context.Context) {
+		if err := b.balances.Invalidate(ctx, userID); err != nil {
+			b.logger.Warn("usecase[balance-cache]", "invalidateAfterCommit", "Failed to invalidate cached balance", err, userID)
+		}
+	})
+}
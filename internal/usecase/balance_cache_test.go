@@ -0,0 +1,250 @@
+package usecase_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/internal/repository/memory"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+	"github.com/google/uuid"
+)
+
+type spyTxKey struct{}
+
+// spyTxManager marks the context of every unit of work, so the balance cache
+// can tell whether it is called from inside one.
+type spyTxManager struct {
+	repository.ITxManager
+}
+
+func (m *spyTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.ITxManager.WithinTx(ctx, func(ctx context.Context) error {
+		return fn(context.WithValue(ctx, spyTxKey{}, true))
+	})
+}
+
+// fakeBalanceCache caches balances in a map and records every Invalidate.
+// It holds its lock while loading, so a load never overwrites a newer
+// invalidation.
+type fakeBalanceCache struct {
+	mu            sync.Mutex
+	balances      map[uuid.UUID]model.UserBalance
+	invalidations int
+	insideTx      int
+}
+
+func newFakeBalanceCache() *fakeBalanceCache {
+	return &fakeBalanceCache{
+		balances: make(map[uuid.UUID]model.UserBalance),
+	}
+}
+
+func (c *fakeBalanceCache) GetOrLoad(ctx context.Context, userID uuid.UUID, _ time.Duration, load func(ctx context.Context, userID uuid.UUID) (*model.UserBalance, error)) (*model.UserBalance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if balance, ok := c.balances[userID]; ok {
+		return &balance, nil
+	}
+	balance, err := load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	c.balances[userID] = *balance
+	return balance, nil
+}
+
+func (c *fakeBalanceCache) Invalidate(ctx context.Context, userID uuid.UUID) error {
+	if ctx.Value(spyTxKey{}) != nil {
+		c.mu.Lock()
+		c.insideTx++
+		c.mu.Unlock()
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidations++
+	delete(c.balances, userID)
+	return nil
+}
+
+func (c *fakeBalanceCache) counts() (invalidations int, insideTx int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.invalidations, c.insideTx
+}
+
+func (c *fakeBalanceCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidations, c.insideTx = 0, 0
+}
+
+func minor(amount *money.Amount) int64 {
+	if amount == nil {
+		return 0
+	}
+	return amount.Minor()
+}
+
+// newCachedTestEnv runs the usecases on the in-memory repository with the
+// balance cache replaced by a fakeBalanceCache.
+func newCachedTestEnv(t *testing.T) (*testEnv, *fakeBalanceCache) {
+	t.Helper()
+
+	repo := memory.New()
+	cache := newFakeBalanceCache()
+	repo.ITxManager = &spyTxManager{ITxManager: repo.ITxManager}
+	repo.IBalanceCache = cache
+	return newTestEnvWithRepo(t, repo), cache
+}
+
+func TestWithdrawUserBalanceInvalidatesCache(t *testing.T) {
+	const (
+		number  = "12345678903"
+		balance = 50000
+	)
+
+	tests := []struct {
+		name              string
+		order             string
+		sum               int64
+		wantCode          errs.CodeEnum
+		wantInvalidations int
+		wantBalance       int64
+	}{
+		{name: "committed", order: number, sum: 20000, wantCode: noError, wantInvalidations: 1, wantBalance: 30000},
+		{name: "rolled back on insufficient funds", order: number, sum: balance + 1, wantCode: errs.CodeNotEnoughBalance, wantBalance: balance},
+		{name: "rolled back on unknown order", order: "79927398713", sum: 100, wantCode: errs.CodeOrderDoesNotExists, wantBalance: balance},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, cache := newCachedTestEnv(t)
+			ctx := env.registerUser(t, "user")
+			env.fundUser(t, ctx, number, balance)
+
+			// Warm the cache, a missed invalidation serves this balance.
+			if current, _ := env.balance(t, ctx); current != balance {
+				t.Fatalf("balance before the withdrawal = %d, want %d", current, balance)
+			}
+			cache.reset()
+
+			err := env.usecase.WithdrawUserBalance(ctx, model.UserWithdrawalInput{
+				OrderNumber: generics.Pointer(tt.order),
+				Sum:         generics.Pointer(money.FromMinor(tt.sum)),
+			})
+			checkErrCode(t, err, tt.wantCode)
+
+			invalidations, insideTx := cache.counts()
+			if insideTx != 0 {
+				t.Errorf("Invalidate called %d times inside the unit of work", insideTx)
+			}
+			if invalidations != tt.wantInvalidations {
+				t.Errorf("Invalidate called %d times, want %d", invalidations, tt.wantInvalidations)
+			}
+			if current, _ := env.balance(t, ctx); current != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", current, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestWithdrawUserBalanceConcurrentCachedReads(t *testing.T) {
+	const (
+		withdrawals = 20
+		readers     = 4
+		sum         = 1000
+		funded      = 10 * sum
+	)
+
+	env, cache := newCachedTestEnv(t)
+	ctx := env.registerUser(t, "user")
+	env.fundUser(t, ctx, "12345678903", funded)
+	cache.reset()
+
+	done := make(chan struct{})
+	readErrs := make(chan error, readers)
+	var readersWG sync.WaitGroup
+	for range readers {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				balance, err := env.usecase.GetUserBalance(ctx)
+				if err != nil {
+					readErrs <- err
+					return
+				}
+				// Cached or not, a balance is a committed state.
+				if total := minor(balance.Current) + minor(balance.Withdrawn); total != funded {
+					readErrs <- fmt.Errorf("read balance %d + %d, want a total of %d", minor(balance.Current), minor(balance.Withdrawn), funded)
+					return
+				}
+			}
+		}()
+	}
+
+	var (
+		writersWG sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range withdrawals {
+		writersWG.Add(1)
+		go func() {
+			defer writersWG.Done()
+			err := env.usecase.WithdrawUserBalance(ctx, model.UserWithdrawalInput{
+				OrderNumber: generics.Pointer("12345678903"),
+				Sum:         generics.Pointer(money.FromMinor(sum)),
+			})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+				return
+			}
+			if code := errCode(err); code != errs.CodeNotEnoughBalance {
+				t.Errorf("withdraw: %v", err)
+			}
+		}()
+	}
+	writersWG.Wait()
+	close(done)
+	readersWG.Wait()
+	close(readErrs)
+	for err := range readErrs {
+		t.Error(err)
+	}
+
+	if succeeded != funded/sum {
+		t.Errorf("%d withdrawals succeeded, want %d", succeeded, funded/sum)
+	}
+	invalidations, insideTx := cache.counts()
+	if insideTx != 0 {
+		t.Errorf("Invalidate called %d times inside the unit of work", insideTx)
+	}
+	if invalidations != succeeded {
+		t.Errorf("Invalidate called %d times, want one per committed withdrawal (%d)", invalidations, succeeded)
+	}
+
+	// After the last commit the cache serves the final balance.
+	current, withdrawn := env.balance(t, ctx)
+	if current != 0 || withdrawn != funded {
+		t.Errorf("balance = %d/%d, want 0/%d", current, withdrawn, funded)
+	}
+}
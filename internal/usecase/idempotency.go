@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/google/uuid"
+)
+
+type idempotencyUsecase struct {
+	cfg              *config.Config
+	logger           logger.Logger
+	idempotencyCache repository.IIdempotencyCache
+}
+
+func newIdempotencyUsecase(cfg *config.Config, logger logger.Logger, idempotencyCache repository.IIdempotencyCache) *idempotencyUsecase {
+	return &idempotencyUsecase{
+		cfg:              cfg,
+		logger:           logger,
+		idempotencyCache: idempotencyCache,
+	}
+}
+
+// ReserveIdempotencyKey claims key for the current user. When the key was
+// already used it returns the stored record for replay, or an error if the
+// request differs or the original one is still running. The claim expires
+// after IdempotencyLockTTL unless the response is stored before.
+func (u *idempotencyUsecase) ReserveIdempotencyKey(ctx context.Context, key string, fingerprint string) (*model.IdempotencyRecord, error) {
+	record, reserved, err := u.idempotencyCache.Reserve(ctx, u.scopedKey(ctx, key), fingerprint, u.cfg.AppGofemart.IdempotencyLockTTL)
+	if err != nil {
+		u.logger.Error("usecase[idempotency]", "ReserveIdempotencyKey", "Failed to reserve idempotency key", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumIdempotency, err)
+	}
+
+	if reserved {
+		return nil, nil
+	}
+
+	if record.Fingerprint != fingerprint {
+		return nil, errs.ErrIdempotencyKeyMismatch
+	}
+
+	if !record.Completed {
+		return nil, errs.ErrIdempotencyKeyInProgress
+	}
+
+	return record, nil
+}
+
+func (u *idempotencyUsecase) CompleteIdempotencyKey(ctx context.Context, key string, record model.IdempotencyRecord) error {
+	record.Completed = true
+	if err := u.idempotencyCache.Complete(ctx, u.scopedKey(ctx, key), &record, u.cfg.AppGofemart.IdempotencyKeyTTL); err != nil {
+		u.logger.Error("usecase[idempotency]", "CompleteIdempotencyKey", "Failed to store idempotent response", err)
+		return wrapUsecaseError(model.EventTypeEnumIdempotency, err)
+	}
+	return nil
+}
+
+func (u *idempotencyUsecase) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if err := u.idempotencyCache.Release(ctx, u.scopedKey(ctx, key)); err != nil {
+		u.logger.Error("usecase[idempotency]", "ReleaseIdempotencyKey", "Failed to release idempotency key", err)
+		return wrapUsecaseError(model.EventTypeEnumIdempotency, err)
+	}
+	return nil
+}
+
+func (u *idempotencyUsecase) scopedKey(ctx context.Context, key string) string {
+	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
+	return userID.String() + ":" + key
+}
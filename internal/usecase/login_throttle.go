@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+)
+
+// loginThrottle limits failed logins per login and per client IP. Once a key
+// reaches its limit it is locked, and every further failure doubles the
+// lockout up to LoginLockoutMax. Redis errors let the attempt through: an
+// outage of the cache must not lock every user out.
+type loginThrottle struct {
+	cfg           *config.Config
+	logger        logger.Logger
+	loginAttempts repository.ILoginAttemptCache
+}
+
+func newLoginThrottle(cfg *config.Config, logger logger.Logger, loginAttempts repository.ILoginAttemptCache) *loginThrottle {
+	return &loginThrottle{
+		cfg:           cfg,
+		logger:        logger,
+		loginAttempts: loginAttempts,
+	}
+}
+
+// lockout returns the longest remaining lockout of the login and the client.
+func (t *loginThrottle) lockout(ctx context.Context, login string, clientIP string) time.Duration {
+	var longest time.Duration
+	for _, key := range t.keys(login, clientIP) {
+		d, err := t.loginAttempts.GetLockout(ctx, key)
+		if err != nil {
+			t.logger.Warn("usecase[login-throttle]", "lockout", "Failed to get lockout", err, key)
+			continue
+		}
+		longest = max(longest, d)
+	}
+	return longest
+}
+
+func (t *loginThrottle) registerFailure(ctx context.Context, login string, clientIP string) {
+	limits := []int64{t.cfg.AppGofemart.LoginMaxAttempts, t.cfg.AppGofemart.LoginMaxAttemptsPerIP}
+
+	for i, key := range t.keys(login, clientIP) {
+		failures, err := t.loginAttempts.RegisterFailure(ctx, key, t.cfg.AppGofemart.LoginAttemptWindow)
+		if err != nil {
+			t.logger.Warn("usecase[login-throttle]", "registerFailure", "Failed to register login failure", err, key)
+			continue
+		}
+
+		if failures < limits[i] {
+			continue
+		}
+
+		if err := t.loginAttempts.SetLockout(ctx, key, t.lockoutDuration(failures-limits[i])); err != nil {
+			t.logger.Warn("usecase[login-throttle]", "registerFailure", "Failed to set lockout", err, key)
+		}
+	}
+}
+
+// registerSuccess clears the failures of the login. The client counter is
+// kept, otherwise an attacker could reset it with an account of their own.
+func (t *loginThrottle) registerSuccess(ctx context.Context, login string) {
+	if err := t.loginAttempts.ResetFailures(ctx, loginKey(login)); err != nil {
+		t.logger.Warn("usecase[login-throttle]", "registerSuccess", "Failed to reset login failures", err)
+	}
+}
+
+func (t *loginThrottle) lockoutDuration(excess int64) time.Duration {
+	d := t.cfg.AppGofemart.LoginLockoutBase
+	for i := int64(0); i < excess && d < t.cfg.AppGofemart.LoginLockoutMax; i++ {
+		d *= 2
+	}
+	return min(d, t.cfg.AppGofemart.LoginLockoutMax)
+}
+
+func (t *loginThrottle) keys(login string, clientIP string) []string {
+	return []string{loginKey(login), "ip:" + clientIP}
+}
+
+func loginKey(login string) string {
+	return "login:" + strings.ToLower(login)
+}
@@ -12,7 +12,9 @@ var errorMapping = map[pghelpers.PgErrorCode]map[model.EventTypeEnum]*errs.Custo
 		model.EventTypeEnumCreateOrder:  errs.New(errs.CodeOrderByAnotherUserUpload, "current order already uploaded by another user"),
 	},
 	pghelpers.ErrNoRows: {
-		model.EventTypeEnumLoginUser:   errs.New(errs.CodeUserNotFound, "user not found"),
 		model.EventTypeEnumGetUserByID: errs.New(errs.CodeUnauthorized, "user creds not valid"),
 	},
+	pghelpers.ErrCheckViolation: {
+		model.EventTypeEnumWithdrawUserBalance: errs.ErrNotEnoughBalance,
+	},
 }
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/google/uuid"
+)
+
+const defaultListLimit = 100
+
+// newListFilter validates query and turns it into a repository filter. One
+// row more than the page size is requested to learn whether a next page
+// exists.
+func newListFilter(query model.ListQuery) (model.ListFilter, error) {
+	filter := model.ListFilter{
+		Limit: query.Limit,
+		From:  query.From,
+		To:    query.To,
+		Sort:  query.Sort,
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultListLimit
+	}
+	filter.Limit++
+
+	if filter.Sort == "" {
+		filter.Sort = model.SortDirectionEnumAsc
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return model.ListFilter{}, errs.ErrInvalidRequest
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Sort != filter.Sort {
+			return model.ListFilter{}, errs.ErrInvalidRequest
+		}
+		filter.After = cursor
+	}
+
+	return filter, nil
+}
+
+// newPage trims the extra row fetched by newListFilter and, when it was
+// there, points the next cursor at the last returned item.
+func newPage[T any](items []T, filter model.ListFilter, key func(T) (time.Time, uuid.UUID)) model.Page[T] {
+	pageSize := int(filter.Limit - 1)
+	if len(items) <= pageSize {
+		return model.Page[T]{Items: items}
+	}
+
+	items = items[:pageSize]
+	t, id := key(items[pageSize-1])
+
+	return model.Page[T]{
+		Items:      items,
+		NextCursor: encodeCursor(model.PageCursor{Time: t, ID: id, Sort: filter.Sort}),
+	}
+}
+
+func encodeCursor(cursor model.PageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*model.PageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor model.PageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/FlyKarlik/gofemart/pkg/jwt"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/google/uuid"
+)
+
+type tokenUsecase struct {
+	cfg           *config.Config
+	logger        logger.Logger
+	userRepo      repository.IUserRepository
+	tokenRepo     repository.ITokenRepository
+	tokenDenylist repository.ITokenDenylist
+	keys          *jwt.KeySet
+}
+
+func newTokenUsecase(cfg *config.Config, logger logger.Logger, userRepo repository.IUserRepository, tokenRepo repository.ITokenRepository, tokenDenylist repository.ITokenDenylist, keys *jwt.KeySet) *tokenUsecase {
+	return &tokenUsecase{
+		cfg:           cfg,
+		logger:        logger,
+		userRepo:      userRepo,
+		tokenRepo:     tokenRepo,
+		tokenDenylist: tokenDenylist,
+		keys:          keys,
+	}
+}
+
+// RefreshTokens exchanges a refresh token for a new token pair. Each refresh
+// token is accepted once: presenting an already rotated one is treated as
+// theft and revokes every token issued from the same login.
+func (u *tokenUsecase) RefreshTokens(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	tokenHash := jwt.HashRefreshToken(refreshToken)
+
+	nextToken, next, err := u.newRefreshToken(nil, nil)
+	if err != nil {
+		u.logger.Error("usecase[token]", "RefreshTokens", "Failed to generate refresh token", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumRefreshToken, err)
+	}
+
+	rotated, err := u.tokenRepo.RotateRefreshToken(ctx, tokenHash, next)
+	if err != nil {
+		var pgErr *pghelpers.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pghelpers.ErrNoRows {
+			u.detectReuse(ctx, tokenHash)
+			return nil, errs.ErrInvalidRefreshToken
+		}
+		u.logger.Error("usecase[token]", "RefreshTokens", "Failed to rotate refresh token", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumRefreshToken, err)
+	}
+
+	user, err := u.userRepo.GetUserByID(ctx, *rotated.UserID)
+	if err != nil {
+		u.logger.Error("usecase[token]", "RefreshTokens", "Failed to get user", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumRefreshToken, err)
+	}
+
+	accessToken, err := u.newAccessToken(user)
+	if err != nil {
+		u.logger.Error("usecase[token]", "RefreshTokens", "Failed to generate access token", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumRefreshToken, err)
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: nextToken,
+	}, nil
+}
+
+// LogoutUser revokes the access token of the current request and, when given,
+// the refresh token family it belongs to.
+func (u *tokenUsecase) LogoutUser(ctx context.Context, input model.LogoutInput) error {
+	tokenID := ctx.Value(model.ContextKeyEnumTokenID).(string)
+	expiresAt := ctx.Value(model.ContextKeyEnumTokenExpiresAt).(time.Time)
+
+	if err := u.tokenDenylist.Add(ctx, tokenID, time.Until(expiresAt)); err != nil {
+		u.logger.Error("usecase[token]", "LogoutUser", "Failed to revoke access token", err)
+		return wrapUsecaseError(model.EventTypeEnumLogoutUser, err)
+	}
+
+	if input.RefreshToken == nil {
+		return nil
+	}
+
+	token, err := u.tokenRepo.GetRefreshTokenByHash(ctx, jwt.HashRefreshToken(*input.RefreshToken))
+	if err != nil {
+		var pgErr *pghelpers.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pghelpers.ErrNoRows {
+			return nil
+		}
+		u.logger.Error("usecase[token]", "LogoutUser", "Failed to get refresh token", err)
+		return wrapUsecaseError(model.EventTypeEnumLogoutUser, err)
+	}
+
+	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
+	if *token.UserID != userID {
+		return nil
+	}
+
+	if err := u.tokenRepo.RevokeRefreshTokenFamily(ctx, *token.FamilyID); err != nil {
+		u.logger.Error("usecase[token]", "LogoutUser", "Failed to revoke refresh tokens", err)
+		return wrapUsecaseError(model.EventTypeEnumLogoutUser, err)
+	}
+
+	return nil
+}
+
+func (u *tokenUsecase) ParseAccessToken(token string) (*jwt.Claims, error) {
+	return jwt.ParseToken(token, u.keys)
+}
+
+// GetJWKS returns the public keys access tokens can be verified with.
+func (u *tokenUsecase) GetJWKS() jwt.JWKS {
+	return u.keys.JWKS()
+}
+
+// IsAccessTokenRevoked reports whether the token was revoked on its own at
+// logout or together with every token of its user at a password change.
+func (u *tokenUsecase) IsAccessTokenRevoked(ctx context.Context, claims *jwt.Claims) (bool, error) {
+	revoked, err := u.tokenDenylist.Contains(ctx, claims.ID)
+	if err != nil {
+		u.logger.Error("usecase[token]", "IsAccessTokenRevoked", "Failed to check token denylist", err)
+		return false, wrapUsecaseError(model.EventTypeEnumCheckAccessToken, err)
+	}
+	if revoked {
+		return true, nil
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return true, nil
+	}
+
+	revokedBefore, err := u.tokenDenylist.UserRevokedBefore(ctx, userID)
+	if err != nil {
+		u.logger.Error("usecase[token]", "IsAccessTokenRevoked", "Failed to check user token denylist", err)
+		return false, wrapUsecaseError(model.EventTypeEnumCheckAccessToken, err)
+	}
+	if revokedBefore.IsZero() {
+		return false, nil
+	}
+
+	// Issue times are whole seconds, a token issued in the second of the
+	// revocation may predate it and is revoked as well.
+	return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(revokedBefore), nil
+}
+
+// revokeAccessTokens revokes the access token of the current request and
+// every other access token issued to userID up to now, including the rest
+// of the current second: tokens carry their issue time in whole seconds.
+func (u *tokenUsecase) revokeAccessTokens(ctx context.Context, userID uuid.UUID) error {
+	if tokenID, ok := ctx.Value(model.ContextKeyEnumTokenID).(string); ok {
+		expiresAt := ctx.Value(model.ContextKeyEnumTokenExpiresAt).(time.Time)
+		if err := u.tokenDenylist.Add(ctx, tokenID, time.Until(expiresAt)); err != nil {
+			return err
+		}
+	}
+
+	return u.tokenDenylist.RevokeUser(ctx, userID, time.Now().Truncate(time.Second), u.cfg.AppGofemart.JWTTokenTTL)
+}
+
+// issueTokenPair starts a new refresh token family for user.
+func (u *tokenUsecase) issueTokenPair(ctx context.Context, user *model.User) (*model.TokenPair, error) {
+	accessToken, err := u.newAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, input, err := u.newRefreshToken(user.ID, generics.Pointer(uuid.New()))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := u.tokenRepo.CreateRefreshToken(ctx, input); err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (u *tokenUsecase) newAccessToken(user *model.User) (string, error) {
+	return jwt.GenerateAccessToken(jwt.JWTPayload{
+		Keys:           u.keys,
+		Issuer:         u.cfg.AppGofemart.JWTIssuer,
+		AccessTokenTTL: u.cfg.AppGofemart.JWTTokenTTL,
+		Claims: jwt.Claims{
+			UserID: user.ID.String(),
+			Login:  *user.Login,
+		},
+	})
+}
+
+func (u *tokenUsecase) newRefreshToken(userID *uuid.UUID, familyID *uuid.UUID) (string, model.CreateRefreshTokenInput, error) {
+	token, err := jwt.GenerateRefreshToken()
+	if err != nil {
+		return "", model.CreateRefreshTokenInput{}, err
+	}
+
+	tokenHash := jwt.HashRefreshToken(token)
+	expiresAt := time.Now().Add(u.cfg.AppGofemart.JWTRefreshTokenTTL)
+
+	return token, model.CreateRefreshTokenInput{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: &tokenHash,
+		ExpiresAt: &expiresAt,
+	}, nil
+}
+
+// detectReuse revokes the family of a refresh token that was presented after
+// it had already been rotated.
+func (u *tokenUsecase) detectReuse(ctx context.Context, tokenHash string) {
+	token, err := u.tokenRepo.GetRefreshTokenByHash(ctx, tokenHash)
+	if err != nil || token.RotatedAt == nil {
+		return
+	}
+
+	u.logger.Warn("usecase[token]", "RefreshTokens", "Rotated refresh token reused, revoking family", errs.ErrInvalidRefreshToken, *token.FamilyID)
+	if err := u.tokenRepo.RevokeRefreshTokenFamily(ctx, *token.FamilyID); err != nil {
+		u.logger.Error("usecase[token]", "RefreshTokens", "Failed to revoke refresh token family", err)
+	}
+}
@@ -0,0 +1,60 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/FlyKarlik/gofemart/pkg/jwt"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestIsAccessTokenRevokedAfterPasswordChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		changePassword bool
+		issuedAt       time.Duration
+		wantRevoked    bool
+	}{
+		{name: "no password change", issuedAt: 0, wantRevoked: false},
+		{name: "issued in the second of the change", changePassword: true, issuedAt: 0, wantRevoked: true},
+		{name: "issued before the change", changePassword: true, issuedAt: -time.Minute, wantRevoked: true},
+		{name: "issued after the change", changePassword: true, issuedAt: 2 * time.Second, wantRevoked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := env.registerUser(t, "user")
+
+			claims := &jwt.Claims{
+				UserID: userID(ctx).String(),
+				RegisteredClaims: jwtlib.RegisteredClaims{
+					ID:       uuid.NewString(),
+					IssuedAt: jwtlib.NewNumericDate(time.Now().Add(tt.issuedAt)),
+				},
+			}
+
+			if tt.changePassword {
+				err := env.usecase.ChangePassword(ctx, model.ChangePasswordInput{
+					OldPassword: generics.Pointer(testPassword),
+					NewPassword: generics.Pointer("battery-staple-43"),
+				}, "127.0.0.1")
+				if err != nil {
+					t.Fatalf("change password: %v", err)
+				}
+			}
+
+			revoked, err := env.usecase.IsAccessTokenRevoked(context.Background(), claims)
+			if err != nil {
+				t.Fatalf("check token: %v", err)
+			}
+			if revoked != tt.wantRevoked {
+				t.Errorf("revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
@@ -6,29 +6,62 @@ import (
 	"github.com/FlyKarlik/gofemart/config"
 	"github.com/FlyKarlik/gofemart/internal/model"
 	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/pkg/jwt"
 	"github.com/FlyKarlik/gofemart/pkg/logger"
 	"github.com/google/uuid"
 )
 
 type IUserUsecase interface {
 	RegisterUser(ctx context.Context, input model.UserInput) error
-	LoginUser(ctx context.Context, input model.UserInput) (string, error)
+	LoginUser(ctx context.Context, input model.UserInput, clientIP string) (*model.TokenPair, error)
+	ChangePassword(ctx context.Context, input model.ChangePasswordInput, clientIP string) error
 	GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error)
 
 	CreateUserOrder(ctx context.Context, input model.UserOrderInput) error
-	GetUserOrders(ctx context.Context) ([]model.UserOrder, error)
+	GetUserOrders(ctx context.Context, query model.OrderListQuery) (*model.Page[model.UserOrder], error)
+	SubscribeOrderEvents(ctx context.Context) (<-chan model.OrderEvent, func() error, error)
 
-	GetUserBalance(ctx context.Context) (*model.UserBalance[float64], error)
-	WithdrawUserBalance(ctx context.Context, input model.UserWithdrawalInput[float64]) error
-	GetUserWithdrawals(ctx context.Context) ([]model.UserWithdrawal[float64], error)
+	GetUserBalance(ctx context.Context) (*model.UserBalance, error)
+	WithdrawUserBalance(ctx context.Context, input model.UserWithdrawalInput) error
+	GetUserWithdrawals(ctx context.Context, query model.ListQuery) (*model.Page[model.UserWithdrawal], error)
+	GetUserLedger(ctx context.Context) ([]model.LedgerEntry, error)
+}
+
+type ITokenUsecase interface {
+	RefreshTokens(ctx context.Context, refreshToken string) (*model.TokenPair, error)
+	LogoutUser(ctx context.Context, input model.LogoutInput) error
+	ParseAccessToken(token string) (*jwt.Claims, error)
+	IsAccessTokenRevoked(ctx context.Context, claims *jwt.Claims) (bool, error)
+	GetJWKS() jwt.JWKS
+}
+
+type IAccrualUsecase interface {
+	GetPendingOrders(ctx context.Context, limit uint64) ([]model.UserOrder, error)
+	GetOrderBacklog(ctx context.Context) (*model.OrderBacklog, error)
+	ProcessOrderAccrual(ctx context.Context, order model.UserOrder) error
+}
+
+type IIdempotencyUsecase interface {
+	ReserveIdempotencyKey(ctx context.Context, key string, fingerprint string) (*model.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, record model.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
 }
 
 type Usecase struct {
 	IUserUsecase
+	ITokenUsecase
+	IAccrualUsecase
+	IIdempotencyUsecase
 }
 
-func New(cfg *config.Config, logger logger.Logger, repo *repository.Repository) *Usecase {
+func New(cfg *config.Config, logger logger.Logger, repo *repository.Repository, accrualClient IAccrualClient, keys *jwt.KeySet) *Usecase {
+	tokens := newTokenUsecase(cfg, logger, repo.IUserRepository, repo.ITokenRepository, repo.ITokenDenylist, keys)
+	balances := newBalanceCache(cfg, logger, repo.ITxManager, repo.IUserRepository, repo.IBalanceCache)
+
 	return &Usecase{
-		IUserUsecase: newUserUsecase(cfg, logger, repo.IUserRepository, repo.IUserCache),
+		IUserUsecase:        newUserUsecase(cfg, logger, repo.ITxManager, repo.IUserRepository, repo.IUserCache, repo.IOrderEventBus, tokens, newLoginThrottle(cfg, logger, repo.ILoginAttemptCache), balances),
+		ITokenUsecase:       tokens,
+		IAccrualUsecase:     newAccrualUsecase(cfg, logger, repo.ITxManager, repo.IUserRepository, balances, repo.IOrderEventBus, accrualClient),
+		IIdempotencyUsecase: newIdempotencyUsecase(cfg, logger, repo.IIdempotencyCache),
 	}
 }
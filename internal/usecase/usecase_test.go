@@ -0,0 +1,192 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/internal/repository/memory"
+	"github.com/FlyKarlik/gofemart/internal/usecase"
+	"github.com/FlyKarlik/gofemart/pkg/accrual"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/FlyKarlik/gofemart/pkg/jwt"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+	"github.com/google/uuid"
+)
+
+const testPassword = "correct-horse-42"
+
+// noError is the expected code of a call that must succeed.
+const noError errs.CodeEnum = -1
+
+// fakeAccrualClient answers with the accrual set for an order number, or
+// ErrOrderNotRegistered when there is none.
+type fakeAccrualClient struct {
+	mu       sync.Mutex
+	accruals map[string]accrual.OrderAccrual
+}
+
+func newFakeAccrualClient() *fakeAccrualClient {
+	return &fakeAccrualClient{accruals: make(map[string]accrual.OrderAccrual)}
+}
+
+func (c *fakeAccrualClient) set(number string, status accrual.OrderStatus, amount *int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	orderAccrual := accrual.OrderAccrual{Order: number, Status: status}
+	if amount != nil {
+		orderAccrual.Accrual = generics.Pointer(money.FromMinor(*amount))
+	}
+	c.accruals[number] = orderAccrual
+}
+
+func (c *fakeAccrualClient) GetOrderAccrual(_ context.Context, number string) (*accrual.OrderAccrual, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	orderAccrual, ok := c.accruals[number]
+	if !ok {
+		return nil, accrual.ErrOrderNotRegistered
+	}
+	return &orderAccrual, nil
+}
+
+type testEnv struct {
+	usecase *usecase.Usecase
+	repo    *repository.Repository
+	accrual *fakeAccrualClient
+}
+
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg, err := config.New("")
+	if err != nil {
+		t.Fatalf("read config defaults: %v", err)
+	}
+	cfg.AppGofemart.JWTIssuer = "https://gofemart.test"
+	cfg.AppGofemart.JWTTokenTTL = time.Hour
+	// Hashing is not what these tests are about, keep it cheap.
+	cfg.AppGofemart.PasswordHashAlgorithm = "bcrypt"
+	cfg.AppGofemart.PasswordBcryptCost = 4
+	return cfg
+}
+
+// newTestEnv wires the usecases on top of the in-memory repository.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	return newTestEnvWithRepo(t, memory.New())
+}
+
+func newTestEnvWithRepo(t *testing.T, repo *repository.Repository) *testEnv {
+	t.Helper()
+
+	log, err := logger.New("error")
+	if err != nil {
+		t.Fatalf("create logger: %v", err)
+	}
+	keys, err := jwt.LoadKeySet(jwt.KeySetConfig{HMACSecret: "test-secret"})
+	if err != nil {
+		t.Fatalf("load jwt keys: %v", err)
+	}
+
+	accrualClient := newFakeAccrualClient()
+	return &testEnv{
+		usecase: usecase.New(newTestConfig(t), log, repo, accrualClient, keys),
+		repo:    repo,
+		accrual: accrualClient,
+	}
+}
+
+// registerUser registers login and returns the context of its requests.
+func (e *testEnv) registerUser(t *testing.T, login string) context.Context {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := e.usecase.RegisterUser(ctx, model.UserInput{
+		Login:    generics.Pointer(login),
+		Password: generics.Pointer(testPassword),
+	}); err != nil {
+		t.Fatalf("register %s: %v", login, err)
+	}
+
+	user, err := e.repo.GetUserByLogin(ctx, login)
+	if err != nil {
+		t.Fatalf("get %s: %v", login, err)
+	}
+	return context.WithValue(ctx, model.ContextKeyEnumUserID, *user.ID)
+}
+
+// fundUser uploads number for the user and processes its accrual of amount.
+func (e *testEnv) fundUser(t *testing.T, ctx context.Context, number string, amount int64) {
+	t.Helper()
+
+	if err := e.usecase.CreateUserOrder(ctx, model.UserOrderInput{Number: generics.Pointer(number)}); err != nil {
+		t.Fatalf("upload order %s: %v", number, err)
+	}
+	e.accrual.set(number, accrual.OrderStatusProcessed, &amount)
+	e.processOrder(t, number)
+}
+
+func (e *testEnv) processOrder(t *testing.T, number string) {
+	t.Helper()
+
+	orders, err := e.usecase.GetPendingOrders(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("get pending orders: %v", err)
+	}
+	for _, order := range orders {
+		if *order.Number == number {
+			if err := e.usecase.ProcessOrderAccrual(context.Background(), order); err != nil {
+				t.Fatalf("process order %s: %v", number, err)
+			}
+			return
+		}
+	}
+	t.Fatalf("order %s is not pending", number)
+}
+
+func (e *testEnv) balance(t *testing.T, ctx context.Context) (int64, int64) {
+	t.Helper()
+
+	balance, err := e.usecase.GetUserBalance(ctx)
+	if err != nil {
+		t.Fatalf("get balance: %v", err)
+	}
+	return balance.Current.Minor(), balance.Withdrawn.Minor()
+}
+
+func userID(ctx context.Context) uuid.UUID {
+	return ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
+}
+
+// errCode returns the code of the usecase error err, CodeUnknown for other
+// errors.
+func errCode(err error) errs.CodeEnum {
+	var customErr *errs.CustomError
+	if errors.As(err, &customErr) {
+		return customErr.Code
+	}
+	return errs.CodeUnknown
+}
+
+func checkErrCode(t *testing.T, err error, want errs.CodeEnum) {
+	t.Helper()
+
+	switch {
+	case want == noError && err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	case want != noError && err == nil:
+		t.Fatalf("error = nil, want code %d", want)
+	case want != noError && errCode(err) != want:
+		t.Fatalf("error code = %d, want %d (%v)", errCode(err), want, err)
+	}
+}
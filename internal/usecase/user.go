@@ -2,37 +2,63 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/FlyKarlik/gofemart/config"
 	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/metrics"
 	"github.com/FlyKarlik/gofemart/internal/model"
 	"github.com/FlyKarlik/gofemart/internal/repository"
+	"github.com/FlyKarlik/gofemart/pkg/database/pghelpers"
 	"github.com/FlyKarlik/gofemart/pkg/hash"
-	"github.com/FlyKarlik/gofemart/pkg/jwt"
 	"github.com/FlyKarlik/gofemart/pkg/logger"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+	"github.com/FlyKarlik/gofemart/pkg/password"
 	"github.com/google/uuid"
 )
 
 type userUsecase struct {
-	cfg       *config.Config
-	logger    logger.Logger
-	userCache repository.IUserCache
-	userRepo  repository.IUserRepository
+	cfg         *config.Config
+	logger      logger.Logger
+	txManager   repository.ITxManager
+	userCache   repository.IUserCache
+	userRepo    repository.IUserRepository
+	tokens      *tokenUsecase
+	throttle    *loginThrottle
+	balances    *balanceCache
+	orderEvents repository.IOrderEventBus
+	hasher      *hash.Hasher
+	policy      password.Policy
 }
 
-func newUserUsecase(cfg *config.Config, logger logger.Logger, userRepo repository.IUserRepository, userCache repository.IUserCache) *userUsecase {
+func newUserUsecase(cfg *config.Config, logger logger.Logger, txManager repository.ITxManager, userRepo repository.IUserRepository, userCache repository.IUserCache, orderEvents repository.IOrderEventBus, tokens *tokenUsecase, throttle *loginThrottle, balances *balanceCache) *userUsecase {
 	return &userUsecase{
-		cfg:       cfg,
-		logger:    logger,
-		userCache: userCache,
-		userRepo:  userRepo,
+		cfg:         cfg,
+		logger:      logger,
+		txManager:   txManager,
+		userCache:   userCache,
+		userRepo:    userRepo,
+		tokens:      tokens,
+		throttle:    throttle,
+		balances:    balances,
+		orderEvents: orderEvents,
+		hasher:      newPasswordHasher(cfg),
+		policy: password.Policy{
+			MinLength:  cfg.AppGofemart.PasswordMinLength,
+			MaxLength:  cfg.AppGofemart.PasswordMaxLength,
+			MinClasses: cfg.AppGofemart.PasswordMinClasses,
+		},
 	}
 }
 
 func (u *userUsecase) RegisterUser(ctx context.Context, input model.UserInput) error {
 	if input.Password != nil {
-		hashedPass, err := hash.GenerateFromPassword(*input.Password)
+		if err := u.checkPassword("password", *input.Password, *input.Login); err != nil {
+			return err
+		}
+
+		hashedPass, err := u.hasher.GenerateFromPassword(*input.Password)
 		if err != nil {
 			u.logger.Error("usecase[user]", "RegisterUser", "Failed to generate hash password", err)
 			return wrapUsecaseError(model.EventTypeEnumRegisterUser, err)
@@ -48,15 +74,30 @@ func (u *userUsecase) RegisterUser(ctx context.Context, input model.UserInput) e
 	return nil
 }
 
-func (u *userUsecase) LoginUser(ctx context.Context, input model.UserInput) (string, error) {
+// LoginUser verifies credentials of input. Unknown logins and wrong
+// passwords are indistinguishable for the caller, both in the response and
+// in time spent, and repeated failures lock the login and the client out.
+func (u *userUsecase) LoginUser(ctx context.Context, input model.UserInput, clientIP string) (*model.TokenPair, error) {
+	if lockout := u.throttle.lockout(ctx, *input.Login, clientIP); lockout > 0 {
+		u.logger.Warn("usecase[user]", "LoginUser", "Login is locked out", errs.ErrInvalidLoginOrPassord, *input.Login, clientIP)
+		return nil, errs.NewTooManyLoginAttempts(lockout)
+	}
+
 	user, err := u.userRepo.GetUserByLogin(ctx, *input.Login)
 	if err != nil {
-		u.logger.Error("usecase[user]", "LoginUser", "Failed to get user", err)
-		return "", wrapUsecaseError(model.EventTypeEnumLoginUser, err)
+		var pgErr *pghelpers.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != pghelpers.ErrNoRows {
+			u.logger.Error("usecase[user]", "LoginUser", "Failed to get user", err)
+			return nil, wrapUsecaseError(model.EventTypeEnumLoginUser, err)
+		}
+
+		u.hasher.CompareWithDummyHash(*input.Password)
+		u.throttle.registerFailure(ctx, *input.Login, clientIP)
+		return nil, errs.ErrInvalidLoginOrPassord
 	}
 
 	isVerified := func() bool {
-		if err := hash.CompareHashAndPassword(*user.Password, *input.Password); err != nil {
+		if err := u.hasher.CompareHashAndPassword(*user.Password, *input.Password); err != nil {
 			u.logger.Error("usecase[user]", "LoginUser", "Failed to compare hash and password", err)
 			return false
 		}
@@ -69,47 +110,96 @@ func (u *userUsecase) LoginUser(ctx context.Context, input model.UserInput) (str
 	}()
 
 	if !isVerified {
-		return "", errs.ErrInvalidLoginOrPassord
+		u.throttle.registerFailure(ctx, *input.Login, clientIP)
+		return nil, errs.ErrInvalidLoginOrPassord
 	}
 
-	jwtPayload := jwt.JWTPayload{
-		SecretKey:      u.cfg.AppGofemart.JWTSecret,
-		Issuer:         u.cfg.AppGofemart.JWTIssuer,
-		AccessTokenTTL: u.cfg.AppGofemart.JWTTokenTTL,
-		Claims: jwt.Claims{
-			UserID: user.ID.String(),
-			Login:  *user.Login,
-		},
+	u.throttle.registerSuccess(ctx, *input.Login)
+
+	if u.hasher.NeedsRehash(*user.Password) {
+		u.rehashPassword(ctx, user, *input.Password)
 	}
 
-	accessToken, err := jwt.GenerateAccessToken(jwtPayload)
+	tokens, err := u.tokens.issueTokenPair(ctx, user)
 	if err != nil {
-		u.logger.Error("usecase[user]", "LoginUser", "Failed to generate access token", err)
-		return "", wrapUsecaseError(model.EventTypeEnumLoginUser, err)
+		u.logger.Error("usecase[user]", "LoginUser", "Failed to issue tokens", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumLoginUser, err)
 	}
 
-	return accessToken, nil
+	return tokens, nil
 }
 
-func (u *userUsecase) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error) {
-	user, found, err := u.userCache.Get(ctx, userID)
+// ChangePassword replaces the password of the current user once the current
+// one is confirmed, and signs the user out everywhere. Wrong current
+// passwords count against the same limits as failed logins.
+func (u *userUsecase) ChangePassword(ctx context.Context, input model.ChangePasswordInput, clientIP string) error {
+	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
+
+	user, err := u.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
-		u.logger.Error("usecase[user]", "GetUserByID", "Failed to get user from cache", err)
+		u.logger.Error("usecase[user]", "ChangePassword", "Failed to get user", err)
+		return wrapUsecaseError(model.EventTypeEnumChangePassword, err)
+	}
+
+	if lockout := u.throttle.lockout(ctx, *user.Login, clientIP); lockout > 0 {
+		u.logger.Warn("usecase[user]", "ChangePassword", "Login is locked out", errs.ErrInvalidCurrentPassword, *user.Login, clientIP)
+		return errs.NewTooManyLoginAttempts(lockout)
+	}
+
+	if err := u.hasher.CompareHashAndPassword(*user.Password, *input.OldPassword); err != nil {
+		u.throttle.registerFailure(ctx, *user.Login, clientIP)
+		return errs.ErrInvalidCurrentPassword
 	}
 
-	if found {
-		u.logger.Debug("usecase[user]", "GetUserByID", "User found in cache", userID)
-		return user, nil
+	if *input.NewPassword == *input.OldPassword {
+		return errs.NewValidationFailed(errs.FieldError{
+			Field:   "new_password",
+			Code:    password.ViolationSameAsPrevious,
+			Message: "must differ from the current password",
+		})
+	}
+	if err := u.checkPassword("new_password", *input.NewPassword, *user.Login); err != nil {
+		return err
 	}
 
-	user, err = u.userRepo.GetUserByID(ctx, userID)
+	newHash, err := u.hasher.GenerateFromPassword(*input.NewPassword)
 	if err != nil {
-		u.logger.Error("usecase[user]", "GetUserByID", "Failed to get user from database", err)
-		return nil, wrapUsecaseError(model.EventTypeEnumGetUserByID, err)
+		u.logger.Error("usecase[user]", "ChangePassword", "Failed to generate hash password", err)
+		return wrapUsecaseError(model.EventTypeEnumChangePassword, err)
+	}
+
+	// Access tokens go first: if the change fails after, sessions only have
+	// to refresh, while the other order could leave them valid.
+	if err := u.tokens.revokeAccessTokens(ctx, userID); err != nil {
+		u.logger.Error("usecase[user]", "ChangePassword", "Failed to revoke access tokens", err)
+		return wrapUsecaseError(model.EventTypeEnumChangePassword, err)
+	}
+
+	err = u.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.userRepo.UpdateUserPassword(ctx, userID, *user.Password, newHash); err != nil {
+			return err
+		}
+		return u.tokens.tokenRepo.RevokeUserRefreshTokens(ctx, userID)
+	})
+	if err != nil {
+		// The password was changed since it was checked above.
+		var pgErr *pghelpers.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pghelpers.ErrNoRows {
+			return errs.ErrInvalidCurrentPassword
+		}
+		u.logger.Error("usecase[user]", "ChangePassword", "Failed to update password", err)
+		return wrapUsecaseError(model.EventTypeEnumChangePassword, err)
 	}
 
-	if err := u.userCache.Set(ctx, userID, user, time.Minute*10); err != nil {
-		u.logger.Warn("usecase[user]", "GetUserByID", "Failed to set user to cache", err)
+	u.throttle.registerSuccess(ctx, *user.Login)
+	return nil
+}
+
+func (u *userUsecase) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	user, err := u.userCache.GetOrLoad(ctx, userID, u.cfg.AppGofemart.UserCacheTTL, u.userRepo.GetUserByID)
+	if err != nil {
+		u.logger.Error("usecase[user]", "GetUserByID", "Failed to get user", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumGetUserByID, err)
 	}
 
 	return user, nil
@@ -131,17 +221,32 @@ func (u *userUsecase) CreateUserOrder(ctx context.Context, input model.UserOrder
 	input.Status = &orderStatus
 
 	if _, err := u.userRepo.CreateUserOrder(ctx, input); err != nil {
+		// A concurrent upload of the same number by this user loses the
+		// insert race too, it must still be answered as a re-upload.
+		var pgErr *pghelpers.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pghelpers.ErrUniqueViolation {
+			if owned, checkErr := u.userRepo.CheckUserOrderExists(ctx, *input.Number, userID); checkErr == nil && owned {
+				return errs.ErrOrderAlreadyUpload
+			}
+		}
 		u.logger.Error("usecase[user]", "CreateUserOrder", "Failed to create user order", err)
 		return wrapUsecaseError(model.EventTypeEnumCreateOrder, err)
 	}
 
+	metrics.OrdersUploaded.Inc()
 	return nil
 }
 
-func (u *userUsecase) GetUserOrders(ctx context.Context) ([]model.UserOrder, error) {
+func (u *userUsecase) GetUserOrders(ctx context.Context, query model.OrderListQuery) (*model.Page[model.UserOrder], error) {
 	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
 
-	orders, err := u.userRepo.GetUserOrders(ctx, userID)
+	listFilter, err := newListFilter(query.ListQuery)
+	if err != nil {
+		return nil, err
+	}
+	filter := model.OrderListFilter{ListFilter: listFilter, Statuses: query.Status}
+
+	orders, err := u.userRepo.GetUserOrders(ctx, userID, filter)
 	if err != nil {
 		u.logger.Error("usecase[user]", "GetUserOrdersByUserID", "Failed to get user orders", err)
 		return nil, wrapUsecaseError(model.EventTypeEnumGetUserOrders, err)
@@ -151,94 +256,155 @@ func (u *userUsecase) GetUserOrders(ctx context.Context) ([]model.UserOrder, err
 		return nil, errs.ErrNoOrders
 	}
 
-	return orders, nil
+	page := newPage(orders, listFilter, func(o model.UserOrder) (time.Time, uuid.UUID) {
+		return *o.UploadedAt, *o.ID
+	})
+	return &page, nil
 }
 
-func (u *userUsecase) GetUserBalance(ctx context.Context) (*model.UserBalance[float64], error) {
+// SubscribeOrderEvents streams status and accrual changes of the current
+// user's orders until ctx is done or the returned close func is called.
+func (u *userUsecase) SubscribeOrderEvents(ctx context.Context) (<-chan model.OrderEvent, func() error, error) {
 	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
-	balance, err := u.userRepo.GetUserBalance(ctx, userID)
+
+	events, closeFn, err := u.orderEvents.Subscribe(ctx, userID)
+	if err != nil {
+		u.logger.Error("usecase[user]", "SubscribeOrderEvents", "Failed to subscribe to order events", err)
+		return nil, nil, wrapUsecaseError(model.EventTypeEnumSubscribeOrderEvents, err)
+	}
+
+	return events, closeFn, nil
+}
+
+func (u *userUsecase) GetUserBalance(ctx context.Context) (*model.UserBalance, error) {
+	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
+	balance, err := u.balances.get(ctx, userID)
 	if err != nil {
 		u.logger.Error("usecase[user]", "GetUserBalance", "Failed to get user balance", err)
 		return nil, wrapUsecaseError(model.EventTypeEnumGetUserBalance, err)
 	}
 
-	return &model.UserBalance[float64]{
-		UserID:    balance.UserID,
-		Current:   convertMoneyValueToFloat64(balance.Current),
-		Withdrawn: convertMoneyValueToFloat64(balance.Withdrawn),
-	}, nil
+	return balance, nil
 }
 
-func (u *userUsecase) WithdrawUserBalance(ctx context.Context, input model.UserWithdrawalInput[float64]) error {
+// WithdrawUserBalance checks that the order belongs to the user and debits
+// the balance in one transaction, so neither can change in between.
+func (u *userUsecase) WithdrawUserBalance(ctx context.Context, input model.UserWithdrawalInput) error {
 	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
-	isOrderExists, err := u.userRepo.CheckUserOrderExists(ctx, *input.OrderNumber, userID)
+	input.UserID = &userID
+
+	err := u.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		isOrderExists, err := u.userRepo.CheckUserOrderExists(ctx, *input.OrderNumber, userID)
+		if err != nil {
+			u.logger.Error("usecase[user]", "WithdrawUserBalance", "Failed to check order exists", err)
+			return err
+		}
+
+		if !isOrderExists {
+			return errs.ErrOrderDoesNotExists
+		}
+
+		if _, err := u.userRepo.CreateUserWithdrawal(ctx, input); err != nil {
+			u.logger.Error("usecase[user]", "WithdrawUserBalance", "Failed to create user withdrawal", err)
+			return err
+		}
+
+		u.balances.invalidateAfterCommit(ctx, userID)
+		return nil
+	})
 	if err != nil {
-		u.logger.Error("usecase[user]", "WithdrawUserBalance", "Failed to check order exists", err)
+		if errors.Is(err, errs.ErrOrderDoesNotExists) {
+			return err
+		}
 		return wrapUsecaseError(model.EventTypeEnumWithdrawUserBalance, err)
 	}
 
-	if !isOrderExists {
-		return errs.ErrOrderDoesNotExists
+	metrics.Withdrawals.Inc()
+	metrics.PointsWithdrawn.Add(float64(input.Sum.Minor()) / money.Scale)
+
+	return nil
+}
+
+func (u *userUsecase) GetUserWithdrawals(ctx context.Context, query model.ListQuery) (*model.Page[model.UserWithdrawal], error) {
+	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
+
+	filter, err := newListFilter(query)
+	if err != nil {
+		return nil, err
 	}
 
-	balance, err := u.userRepo.GetUserBalance(ctx, userID)
+	withdrawals, err := u.userRepo.GetUserWithdrawals(ctx, userID, filter)
 	if err != nil {
-		u.logger.Error("usecase[user]", "WithdrawUserBalance", "Failed to get user balance", err)
-		return wrapUsecaseError(model.EventTypeEnumWithdrawUserBalance, err)
+		u.logger.Error("usecase[user]", "GetUserWithdrawals", "Failed to get user withdrawals", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumGetUserWithdrawals, err)
 	}
 
-	if *balance.Current < *convertMoneyValueToInt64(input.Sum) {
-		return errs.ErrNotEnoughBalance
+	if len(withdrawals) == 0 {
+		return nil, errs.ErrNooneWithdrawal
 	}
 
-	prepareWitdrawal := model.UserWithdrawalInput[int64]{
-		UserID: &userID,
-		WitdrawnBalance: func() *int64 {
-			withdrawnBalance := *balance.Withdrawn + *convertMoneyValueToInt64(input.Sum)
-			return &withdrawnBalance
-		}(),
-		CurrentBalance: func() *int64 {
-			currentBalance := *balance.Current - *convertMoneyValueToInt64(input.Sum)
-			return &currentBalance
-		}(),
-		OrderNumber: input.OrderNumber,
-		Sum:         convertMoneyValueToInt64(input.Sum),
+	page := newPage(withdrawals, filter, func(w model.UserWithdrawal) (time.Time, uuid.UUID) {
+		return *w.ProcessedAt, *w.ID
+	})
+	return &page, nil
+}
+
+func (u *userUsecase) GetUserLedger(ctx context.Context) ([]model.LedgerEntry, error) {
+	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
+	entries, err := u.userRepo.GetUserLedger(ctx, userID)
+	if err != nil {
+		u.logger.Error("usecase[user]", "GetUserLedger", "Failed to get user ledger", err)
+		return nil, wrapUsecaseError(model.EventTypeEnumGetUserLedger, err)
 	}
 
-	if _, err := u.userRepo.CreateUserWithdrawal(ctx, prepareWitdrawal); err != nil {
-		u.logger.Error("usecase", "WithdrawUserBalance", "Failed to create user withdrawal", err)
-		return wrapUsecaseError(model.EventTypeEnumWithdrawUserBalance, err)
+	return entries, nil
+}
+
+// checkPassword returns a validation error naming field when password breaks
+// the password policy.
+func (u *userUsecase) checkPassword(field string, pass string, login string) error {
+	violations := u.policy.Check(pass, login)
+	if len(violations) == 0 {
+		return nil
 	}
 
-	return nil
+	fields := make([]errs.FieldError, 0, len(violations))
+	for _, v := range violations {
+		fields = append(fields, errs.FieldError{
+			Field:   field,
+			Code:    v.Code,
+			Message: v.Message,
+		})
+	}
+	return errs.NewValidationFailed(fields...)
 }
 
-func (u *userUsecase) GetUserWithdrawals(ctx context.Context) ([]model.UserWithdrawal[float64], error) {
-	userID := ctx.Value(model.ContextKeyEnumUserID).(uuid.UUID)
-	withdrawals, err := u.userRepo.GetUserWithdrawals(ctx, userID)
+// rehashPassword replaces the stored hash of user with one at the current
+// cost. Failing to do so only postpones it to the next login.
+func (u *userUsecase) rehashPassword(ctx context.Context, user *model.User, pass string) {
+	newHash, err := u.hasher.GenerateFromPassword(pass)
 	if err != nil {
-		u.logger.Error("usecase[user]", "WithdrawUserBalance", "Failed to get user balance", err)
-		return nil, wrapUsecaseError(model.EventTypeEnumGetUserWithdrawals, err)
+		u.logger.Warn("usecase[user]", "rehashPassword", "Failed to generate hash password", err)
+		return
 	}
 
-	if len(withdrawals) == 0 {
-		return nil, errs.ErrNooneWithdrawal
+	if err := u.userRepo.UpdateUserPassword(ctx, *user.ID, *user.Password, newHash); err != nil {
+		u.logger.Warn("usecase[user]", "rehashPassword", "Failed to update password hash", err)
 	}
+}
 
-	prepareWithdrawals := func() []model.UserWithdrawal[float64] {
-		withdrawalsList := make([]model.UserWithdrawal[float64], len(withdrawals))
-		for index, witdrawal := range withdrawals {
-			userWithdrawal := model.UserWithdrawal[float64]{
-				ID:          witdrawal.ID,
-				UserID:      witdrawal.UserID,
-				OrderNumber: witdrawal.OrderNumber,
-				Sum:         convertMoneyValueToFloat64(witdrawal.Sum),
-				ProcessedAt: witdrawal.ProcessedAt,
-			}
-			withdrawalsList[index] = userWithdrawal
-		}
-		return withdrawalsList
-	}()
-
-	return prepareWithdrawals, nil
+// newPasswordHasher hashes with the configured algorithm and still verifies
+// hashes of the other one, which rehashPassword then replaces.
+func newPasswordHasher(cfg *config.Config) *hash.Hasher {
+	argon2id := hash.NewArgon2id(hash.Argon2idParams{
+		Memory:  cfg.AppGofemart.PasswordArgon2Memory,
+		Time:    cfg.AppGofemart.PasswordArgon2Time,
+		Threads: cfg.AppGofemart.PasswordArgon2Threads,
+	})
+	bcrypt := hash.NewBcrypt(cfg.AppGofemart.PasswordBcryptCost)
+
+	if cfg.AppGofemart.PasswordHashAlgorithm == "bcrypt" {
+		return hash.New(bcrypt, argon2id)
+	}
+	return hash.New(argon2id, bcrypt)
 }
@@ -0,0 +1,201 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/FlyKarlik/gofemart/internal/errs"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/pkg/generics"
+	"github.com/FlyKarlik/gofemart/pkg/money"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	tests := []struct {
+		name         string
+		register     string
+		password     string
+		wantRegister errs.CodeEnum
+		login        string
+		loginWith    string
+		wantLogin    errs.CodeEnum
+	}{
+		{
+			name:         "registered user logs in",
+			register:     "alice",
+			password:     testPassword,
+			wantRegister: noError,
+			login:        "alice",
+			loginWith:    testPassword,
+			wantLogin:    noError,
+		},
+		{
+			name:         "taken login",
+			register:     "taken",
+			password:     testPassword,
+			wantRegister: errs.CodeLoginInUse,
+		},
+		{
+			name:         "weak password",
+			register:     "bob",
+			password:     "short",
+			wantRegister: errs.CodeValidationFailed,
+		},
+		{
+			name:         "wrong password",
+			register:     "carol",
+			password:     testPassword,
+			wantRegister: noError,
+			login:        "carol",
+			loginWith:    "wrong-password-1",
+			wantLogin:    errs.CodeInvalidLoginOrPassword,
+		},
+		{
+			name:         "unknown login",
+			register:     "dave",
+			password:     testPassword,
+			wantRegister: noError,
+			login:        "nobody",
+			loginWith:    testPassword,
+			wantLogin:    errs.CodeInvalidLoginOrPassword,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.registerUser(t, "taken")
+
+			err := env.usecase.RegisterUser(context.Background(), model.UserInput{
+				Login:    generics.Pointer(tt.register),
+				Password: generics.Pointer(tt.password),
+			})
+			checkErrCode(t, err, tt.wantRegister)
+			if tt.login == "" {
+				return
+			}
+
+			tokens, err := env.usecase.LoginUser(context.Background(), model.UserInput{
+				Login:    generics.Pointer(tt.login),
+				Password: generics.Pointer(tt.loginWith),
+			}, "192.0.2.1")
+			checkErrCode(t, err, tt.wantLogin)
+			if tt.wantLogin != noError {
+				return
+			}
+
+			claims, err := env.usecase.ParseAccessToken(tokens.AccessToken)
+			if err != nil {
+				t.Fatalf("parse access token: %v", err)
+			}
+			user, err := env.repo.GetUserByLogin(context.Background(), tt.login)
+			if err != nil {
+				t.Fatalf("get user: %v", err)
+			}
+			if claims.UserID != user.ID.String() {
+				t.Errorf("token user = %s, want %s", claims.UserID, user.ID)
+			}
+			if tokens.RefreshToken == "" {
+				t.Error("no refresh token issued")
+			}
+		})
+	}
+}
+
+func TestCreateUserOrder(t *testing.T) {
+	const number = "12345678903"
+
+	tests := []struct {
+		name     string
+		byOwner  bool
+		number   string
+		wantCode errs.CodeEnum
+	}{
+		{name: "new order", byOwner: true, number: "79927398713", wantCode: noError},
+		{name: "uploaded again by owner", byOwner: true, number: number, wantCode: errs.CodeOrderAlreadyUpload},
+		{name: "uploaded by another user", byOwner: false, number: number, wantCode: errs.CodeOrderByAnotherUserUpload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			owner := env.registerUser(t, "owner")
+			other := env.registerUser(t, "other")
+
+			if err := env.usecase.CreateUserOrder(owner, model.UserOrderInput{Number: generics.Pointer(number)}); err != nil {
+				t.Fatalf("upload first order: %v", err)
+			}
+
+			ctx := other
+			if tt.byOwner {
+				ctx = owner
+			}
+			err := env.usecase.CreateUserOrder(ctx, model.UserOrderInput{Number: generics.Pointer(tt.number)})
+			checkErrCode(t, err, tt.wantCode)
+
+			orders, err := env.repo.GetUserOrders(context.Background(), userID(owner), model.OrderListFilter{})
+			if err != nil {
+				t.Fatalf("get owner orders: %v", err)
+			}
+			wantOrders := 1
+			if tt.wantCode == noError {
+				wantOrders = 2
+			}
+			if len(orders) != wantOrders {
+				t.Errorf("owner has %d orders, want %d", len(orders), wantOrders)
+			}
+		})
+	}
+}
+
+func TestWithdrawUserBalance(t *testing.T) {
+	const (
+		number  = "12345678903"
+		balance = 50000
+	)
+
+	tests := []struct {
+		name          string
+		order         string
+		sum           int64
+		wantCode      errs.CodeEnum
+		wantBalance   int64
+		wantWithdrawn int64
+	}{
+		{name: "covered by balance", order: number, sum: 20000, wantCode: noError, wantBalance: 30000, wantWithdrawn: 20000},
+		{name: "whole balance", order: number, sum: balance, wantCode: noError, wantBalance: 0, wantWithdrawn: balance},
+		{name: "insufficient funds", order: number, sum: balance + 1, wantCode: errs.CodeNotEnoughBalance, wantBalance: balance},
+		{name: "unknown order", order: "79927398713", sum: 100, wantCode: errs.CodeOrderDoesNotExists, wantBalance: balance},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := env.registerUser(t, "user")
+			env.fundUser(t, ctx, number, balance)
+
+			err := env.usecase.WithdrawUserBalance(ctx, model.UserWithdrawalInput{
+				OrderNumber: generics.Pointer(tt.order),
+				Sum:         generics.Pointer(money.FromMinor(tt.sum)),
+			})
+			checkErrCode(t, err, tt.wantCode)
+
+			current, withdrawn := env.balance(t, ctx)
+			if current != tt.wantBalance || withdrawn != tt.wantWithdrawn {
+				t.Errorf("balance = %d/%d, want %d/%d", current, withdrawn, tt.wantBalance, tt.wantWithdrawn)
+			}
+
+			withdrawals, err := env.repo.GetUserWithdrawals(context.Background(), userID(ctx), model.ListFilter{})
+			if err != nil {
+				t.Fatalf("get withdrawals: %v", err)
+			}
+			wantWithdrawals := 0
+			if tt.wantCode == noError {
+				wantWithdrawals = 1
+			}
+			if len(withdrawals) != wantWithdrawals {
+				t.Errorf("%d withdrawals recorded, want %d", len(withdrawals), wantWithdrawals)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/config"
+	"github.com/FlyKarlik/gofemart/internal/model"
+	"github.com/FlyKarlik/gofemart/internal/usecase"
+	"github.com/FlyKarlik/gofemart/pkg/logger"
+)
+
+// AccrualWorker periodically picks up NEW/PROCESSING orders and asks the
+// accrual system for their state until they become PROCESSED or INVALID.
+type AccrualWorker struct {
+	cfg     *config.Config
+	logger  logger.Logger
+	usecase *usecase.Usecase
+}
+
+func NewAccrualWorker(cfg *config.Config, logger logger.Logger, usecase *usecase.Usecase) *AccrualWorker {
+	return &AccrualWorker{
+		cfg:     cfg,
+		logger:  logger,
+		usecase: usecase,
+	}
+}
+
+// Run blocks until ctx is cancelled; the batch in flight is finished first.
+func (w *AccrualWorker) Run(ctx context.Context) {
+	w.logger.Info("worker[accrual]", "AccrualWorker.Run", "Accrual worker started...")
+
+	ticker := time.NewTicker(w.cfg.AppGofemart.AccrualPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("worker[accrual]", "AccrualWorker.Run", "Accrual worker stopped")
+			return
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+func (w *AccrualWorker) poll(ctx context.Context) {
+	orders, err := w.usecase.GetPendingOrders(ctx, w.cfg.AppGofemart.AccrualBatchSize)
+	if err != nil {
+		w.logger.Error("worker[accrual]", "AccrualWorker.poll", "Failed to get pending orders", err)
+		return
+	}
+
+	if len(orders) == 0 {
+		return
+	}
+
+	// The batch is claimed, so it is finished even when ctx is cancelled
+	// meanwhile: an order left out waits for its backoff before the next try.
+	ctx = context.WithoutCancel(ctx)
+
+	jobs := make(chan model.UserOrder)
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.AppGofemart.AccrualWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for order := range jobs {
+				if err := w.usecase.ProcessOrderAccrual(ctx, order); err != nil {
+					w.logger.Error("worker[accrual]", "AccrualWorker.poll", "Failed to process order accrual", err, *order.Number)
+				}
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	for _, order := range orders {
+		jobs <- order
+	}
+}
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE user_balance
+    DROP CONSTRAINT IF EXISTS user_balance_current_non_negative;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE user_balance
+    ADD CONSTRAINT user_balance_current_non_negative CHECK ("current" >= 0);
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+CREATE TABLE user_balance (
+    user_id UUID PRIMARY KEY REFERENCES "user"(id),
+    "current" BIGINT NOT NULL DEFAULT 0,
+    withdrawn BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT user_balance_current_non_negative CHECK ("current" >= 0)
+);
+
+INSERT INTO user_balance (user_id, "current", withdrawn)
+SELECT u.id, COALESCE(l.balance_after, 0), COALESCE(l.withdrawn_after, 0)
+FROM "user" u
+LEFT JOIN LATERAL (
+    SELECT balance_after, withdrawn_after
+    FROM ledger_entry
+    WHERE user_id = u.id
+    ORDER BY seq DESC
+    LIMIT 1
+) l ON true;
+
+DROP TABLE IF EXISTS ledger_entry;
+DROP FUNCTION IF EXISTS ledger_entry_append_only();
+
+COMMIT;
@@ -0,0 +1,51 @@
+BEGIN;
+
+-- Every row is a complete double-entry posting: amount moves from
+-- debit_account to credit_account. The USER account is the points wallet of
+-- user_id; ACCRUAL, WITHDRAWAL and ADJUSTMENT are system counter-accounts.
+-- balance_after and withdrawn_after carry the running totals of the wallet
+-- and can always be re-derived by summing the postings in seq order.
+CREATE TABLE ledger_entry (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    seq BIGINT GENERATED ALWAYS AS IDENTITY UNIQUE,
+    user_id UUID NOT NULL REFERENCES "user"(id),
+    entry_type TEXT NOT NULL CHECK (entry_type IN ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL')),
+    debit_account TEXT NOT NULL CHECK (debit_account IN ('USER', 'ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT')),
+    credit_account TEXT NOT NULL CHECK (credit_account IN ('USER', 'ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT')),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    balance_after BIGINT NOT NULL CHECK (balance_after >= 0),
+    withdrawn_after BIGINT NOT NULL CHECK (withdrawn_after >= 0),
+    order_number TEXT,
+    reversal_of UUID UNIQUE REFERENCES ledger_entry(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (debit_account <> credit_account),
+    CHECK ('USER' IN (debit_account, credit_account)),
+    CHECK ((entry_type = 'REVERSAL') = (reversal_of IS NOT NULL))
+);
+
+CREATE INDEX idx_ledger_entry_user_id_seq ON ledger_entry(user_id, seq);
+
+CREATE FUNCTION ledger_entry_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger_entry is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entry_append_only
+    BEFORE UPDATE OR DELETE ON ledger_entry
+    FOR EACH ROW EXECUTE FUNCTION ledger_entry_append_only();
+
+-- Carry existing counters over as opening postings.
+INSERT INTO ledger_entry (user_id, entry_type, debit_account, credit_account, amount, balance_after, withdrawn_after)
+SELECT user_id, 'ADJUSTMENT', 'ADJUSTMENT', 'USER', "current" + withdrawn, "current" + withdrawn, 0
+FROM user_balance
+WHERE "current" + withdrawn > 0;
+
+INSERT INTO ledger_entry (user_id, entry_type, debit_account, credit_account, amount, balance_after, withdrawn_after)
+SELECT user_id, 'WITHDRAWAL', 'USER', 'WITHDRAWAL', withdrawn, "current", withdrawn
+FROM user_balance
+WHERE withdrawn > 0;
+
+DROP TABLE user_balance;
+
+COMMIT;
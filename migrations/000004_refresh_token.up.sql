@@ -0,0 +1,20 @@
+BEGIN;
+
+-- Refresh tokens are opaque, only their SHA-256 is stored. Every rotation
+-- keeps the family_id of the login that started the chain, so presenting an
+-- already rotated token revokes the whole chain.
+CREATE TABLE refresh_token (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES "user"(id),
+    family_id UUID NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    rotated_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_refresh_token_family_id ON refresh_token(family_id);
+CREATE INDEX idx_refresh_token_user_id ON refresh_token(user_id);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS idx_user_order_user_id ON user_order(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_withdrawal_user_id ON user_withdrawal(user_id);
+
+DROP INDEX IF EXISTS idx_user_order_user_id_uploaded_at;
+DROP INDEX IF EXISTS idx_user_withdrawal_user_id_processed_at;
+
+COMMIT;
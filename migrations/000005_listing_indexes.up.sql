@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Keyset pagination walks (user_id, time, id). The composite indexes also
+-- serve plain lookups by user_id, so the old ones are dropped.
+CREATE INDEX idx_user_order_user_id_uploaded_at ON user_order(user_id, uploaded_at, id);
+CREATE INDEX idx_user_withdrawal_user_id_processed_at ON user_withdrawal(user_id, processed_at, id);
+
+DROP INDEX IF EXISTS idx_user_order_user_id;
+DROP INDEX IF EXISTS idx_user_withdrawal_user_id;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_user_order_pending_next_poll_at;
+
+ALTER TABLE user_order
+    DROP COLUMN IF EXISTS next_poll_at,
+    DROP COLUMN IF EXISTS poll_attempts;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Pending orders are polled again with an exponential backoff, so orders the
+-- accrual system keeps answering the same for do not hold up newer ones.
+ALTER TABLE user_order
+    ADD COLUMN poll_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN next_poll_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+CREATE INDEX idx_user_order_pending_next_poll_at ON user_order(next_poll_at, id)
+    WHERE status IN ('NEW', 'PROCESSING');
+
+COMMIT;
@@ -0,0 +1,156 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FlyKarlik/gofemart/pkg/money"
+)
+
+const defaultRetryAfter = 60 * time.Second
+
+var (
+	ErrOrderNotRegistered = errors.New("order is not registered in accrual system")
+	ErrTooManyRequests    = errors.New("accrual system rate limit exceeded")
+	ErrUnexpectedStatus   = errors.New("unexpected accrual system response status")
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusRegistered OrderStatus = "REGISTERED"
+	OrderStatusProcessing OrderStatus = "PROCESSING"
+	OrderStatusInvalid    OrderStatus = "INVALID"
+	OrderStatusProcessed  OrderStatus = "PROCESSED"
+)
+
+func (s OrderStatus) String() string {
+	return string(s)
+}
+
+type OrderAccrual struct {
+	Order   string        `json:"order"`
+	Status  OrderStatus   `json:"status"`
+	Accrual *money.Amount `json:"accrual,omitempty"`
+}
+
+// Client talks to the accrual system. All goroutines sharing a Client pause
+// together once the accrual system answers 429 Too Many Requests, until the
+// Retry-After interval elapses.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+func (c *Client) GetOrderAccrual(ctx context.Context, number string) (*OrderAccrual, error) {
+	if err := c.waitPause(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/orders/"+url.PathEscape(number), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var orderAccrual OrderAccrual
+		if err := json.NewDecoder(resp.Body).Decode(&orderAccrual); err != nil {
+			return nil, err
+		}
+		return &orderAccrual, nil
+	case http.StatusNoContent:
+		return nil, ErrOrderNotRegistered
+	case http.StatusTooManyRequests:
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		c.pause(retryAfter)
+		return nil, fmt.Errorf("%w: retry after %s", ErrTooManyRequests, retryAfter)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+}
+
+// PausedUntil reports the moment the client resumes sending requests.
+func (c *Client) PausedUntil() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pausedUntil
+}
+
+func (c *Client) pause(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(c.pausedUntil) {
+		c.pausedUntil = until
+	}
+}
+
+func (c *Client) waitPause(ctx context.Context) error {
+	wait := time.Until(c.PausedUntil())
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: delay in seconds
+// and an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return defaultRetryAfter
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return defaultRetryAfter
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+
+	return defaultRetryAfter
+}
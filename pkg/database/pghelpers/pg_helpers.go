@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/FlyKarlik/gofemart/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -62,3 +63,18 @@ func FromNullInt64(n sql.NullInt64) *int64 {
 	}
 	return nil
 }
+
+func ToNullMoney(a *money.Amount) sql.NullInt64 {
+	if a != nil {
+		return sql.NullInt64{Int64: a.Minor(), Valid: true}
+	}
+	return sql.NullInt64{}
+}
+
+func FromNullMoney(n sql.NullInt64) *money.Amount {
+	if n.Valid {
+		amount := money.FromMinor(n.Int64)
+		return &amount
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package pghelpers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const retryBaseDelay = 10 * time.Millisecond
+
+// IsRetryable reports whether err is a transient transaction conflict that is
+// safe to retry from scratch.
+func IsRetryable(err error) bool {
+	var pgErr *PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == ErrSerialization || pgErr.Code == ErrDeadlock
+	}
+	return false
+}
+
+// Retry runs fn up to attempts times while it fails with a retryable error,
+// doubling the pause between attempts.
+func Retry(ctx context.Context, attempts int, fn func() error) error {
+	var err error
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !IsRetryable(err) || attempt >= attempts {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,64 @@
+package pghelpers
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRetry(t *testing.T) {
+	errSerialization := newPgError(ErrSerialization, "serialization failure", nil)
+	errDeadlock := newPgError(ErrDeadlock, "deadlock detected", nil)
+	errCheck := newPgError(ErrCheckViolation, "check violation", nil)
+
+	tests := []struct {
+		name         string
+		attempts     int
+		errs         []error
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "success", attempts: 3, errs: []error{nil}, wantAttempts: 1},
+		{name: "retries serialization failure", attempts: 3, errs: []error{errSerialization, nil}, wantAttempts: 2},
+		{name: "retries deadlock", attempts: 3, errs: []error{errDeadlock, errSerialization, nil}, wantAttempts: 3},
+		{name: "gives up after attempts", attempts: 2, errs: []error{errSerialization, errSerialization, nil}, wantErr: errSerialization, wantAttempts: 2},
+		{name: "does not retry other errors", attempts: 3, errs: []error{errCheck, nil}, wantErr: errCheck, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			err := Retry(context.Background(), tt.attempts, func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", calls, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryStopsOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	errSerialization := newPgError(ErrSerialization, "serialization failure", nil)
+	var calls int
+	err := Retry(ctx, 5, func() error {
+		calls++
+		return errSerialization
+	})
+
+	if !errors.Is(err, errSerialization) {
+		t.Errorf("err = %v, want %v", err, errSerialization)
+	}
+	if calls != 1 {
+		t.Errorf("attempts = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,41 @@
+package hash
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestArgon2idCompare(t *testing.T) {
+	argon2id := NewArgon2id(Argon2idParams{Memory: 64, Time: 1, Threads: 1})
+	hashed, err := argon2id.Hash("password")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	const saltAndKey = "$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	tests := []struct {
+		name     string
+		hashed   string
+		password string
+		wantErr  error
+	}{
+		{name: "matching password", hashed: hashed, password: "password"},
+		{name: "other password", hashed: hashed, password: "other", wantErr: ErrMismatchedHashAndPassword},
+		{name: "zero memory", hashed: "$argon2id$v=19$m=0,t=1,p=1" + saltAndKey, wantErr: ErrUnknownHashFormat},
+		{name: "zero time", hashed: "$argon2id$v=19$m=64,t=0,p=1" + saltAndKey, wantErr: ErrUnknownHashFormat},
+		{name: "zero threads", hashed: "$argon2id$v=19$m=64,t=1,p=0" + saltAndKey, wantErr: ErrUnknownHashFormat},
+		{name: "negative time", hashed: "$argon2id$v=19$m=64,t=-1,p=1" + saltAndKey, wantErr: ErrUnknownHashFormat},
+		{name: "threads out of range", hashed: "$argon2id$v=19$m=64,t=1,p=256" + saltAndKey, wantErr: ErrUnknownHashFormat},
+		{name: "other version", hashed: "$argon2id$v=16$m=64,t=1,p=1" + saltAndKey, wantErr: ErrUnknownHashFormat},
+		{name: "missing key", hashed: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$", wantErr: ErrUnknownHashFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := argon2id.Compare(tt.hashed, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Compare() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
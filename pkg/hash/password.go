@@ -1,17 +1,81 @@
 package hash
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"errors"
+	"sync"
 )
 
-func GenerateFromPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+var (
+	ErrMismatchedHashAndPassword = errors.New("hashed password is not the hash of the given password")
+	ErrUnknownHashFormat         = errors.New("hashed password is of an unknown format")
+)
+
+// Algorithm is one password hashing scheme. Its hashes encode the algorithm
+// and its parameters, so they can be verified after the parameters change.
+type Algorithm interface {
+	Hash(password string) (string, error)
+	// Compare returns ErrMismatchedHashAndPassword when hashedPassword is not
+	// the hash of password.
+	Compare(hashedPassword string, password string) error
+	// Identifies reports whether hashedPassword was made by this algorithm.
+	Identifies(hashedPassword string) bool
+	// NeedsRehash reports whether hashedPassword was made with other
+	// parameters than the algorithm is configured with.
+	NeedsRehash(hashedPassword string) bool
+}
+
+// Hasher hashes new passwords with its preferred algorithm and verifies
+// hashes of any algorithm it knows, so stored hashes can be moved to the
+// preferred one as users log in.
+type Hasher struct {
+	preferred  Algorithm
+	algorithms []Algorithm
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+}
+
+func New(preferred Algorithm, legacy ...Algorithm) *Hasher {
+	return &Hasher{
+		preferred:  preferred,
+		algorithms: append([]Algorithm{preferred}, legacy...),
 	}
-	return string(hashedBytes), nil
 }
 
-func CompareHashAndPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+func (h *Hasher) GenerateFromPassword(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *Hasher) CompareHashAndPassword(hashedPassword, password string) error {
+	algorithm, ok := h.algorithm(hashedPassword)
+	if !ok {
+		return ErrUnknownHashFormat
+	}
+	return algorithm.Compare(hashedPassword, password)
+}
+
+// NeedsRehash reports whether hashedPassword was made with another algorithm
+// or other parameters than new hashes are, so it should be replaced once
+// the password is known.
+func (h *Hasher) NeedsRehash(hashedPassword string) bool {
+	return !h.preferred.Identifies(hashedPassword) || h.preferred.NeedsRehash(hashedPassword)
+}
+
+// CompareWithDummyHash spends the same time as CompareHashAndPassword
+// against a real hash. Calling it for unknown logins keeps response timing
+// from revealing which accounts exist.
+func (h *Hasher) CompareWithDummyHash(password string) {
+	h.dummyHashOnce.Do(func() {
+		h.dummyHash, _ = h.preferred.Hash("dummy-password")
+	})
+	_ = h.preferred.Compare(h.dummyHash, password)
+}
+
+func (h *Hasher) algorithm(hashedPassword string) (Algorithm, bool) {
+	for _, algorithm := range h.algorithms {
+		if algorithm.Identifies(hashedPassword) {
+			return algorithm, true
+		}
+	}
+	return nil, false
 }
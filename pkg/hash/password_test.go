@@ -0,0 +1,24 @@
+package hash
+
+import "testing"
+
+// The benchmarks hash with the configuration defaults, so their results
+// show the cost of a login and a registration.
+
+func BenchmarkArgon2idHash(b *testing.B) {
+	argon2id := NewArgon2id(Argon2idParams{Memory: 19456, Time: 2, Threads: 1})
+	for b.Loop() {
+		if _, err := argon2id.Hash("correct-horse-42"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBcryptHash(b *testing.B) {
+	bcrypt := NewBcrypt(10)
+	for b.Loop() {
+		if _, err := bcrypt.Hash("correct-horse-42"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
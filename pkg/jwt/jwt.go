@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
@@ -13,7 +14,7 @@ var (
 )
 
 type JWTPayload struct {
-	SecretKey      string
+	Keys           *KeySet
 	Issuer         string
 	AccessTokenTTL time.Duration
 	Claims         Claims
@@ -30,18 +31,27 @@ func GenerateAccessToken(payload JWTPayload) (string, error) {
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(payload.AccessTokenTTL)),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 		Issuer:    payload.Issuer,
+		ID:        uuid.NewString(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, payload.Claims)
-	return token.SignedString([]byte(payload.SecretKey))
+	key := payload.Keys.signing
+	token := jwt.NewWithClaims(key.Method, payload.Claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signKey)
 }
 
-func ParseToken(tokenString string, secretKey string) (*Claims, error) {
+// ParseToken verifies tokenString with the key named by its kid header.
+// Tokens without kid are verified with the legacy HMAC key, if configured.
+func ParseToken(tokenString string, keys *KeySet) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.lookup(kid)
+		if !ok || token.Method.Alg() != key.Method.Alg() {
 			return nil, ErrInvalidToken
 		}
-		return []byte(secretKey), nil
+		return key.verifyKey, nil
 	})
 
 	if err != nil {
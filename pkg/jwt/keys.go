@@ -0,0 +1,296 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoSigningKey       = errors.New("no signing key configured")
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+	ErrInvalidPEM         = errors.New("invalid PEM data")
+	ErrDuplicateKeyID     = errors.New("duplicate key id")
+)
+
+// Key is a single signing or verification key. Asymmetric keys are
+// identified by their RFC 7638 thumbprint, the legacy HMAC key has an empty
+// ID and is matched by tokens without a kid header.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   any
+	verifyKey any
+}
+
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+func NewHMACKey(secret string) *Key {
+	return &Key{
+		Method:    jwt.SigningMethodHS512,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// LoadKeyFile reads an RSA or Ed25519 key from a PEM file. A private key
+// can both sign and verify, a public key only verifies.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParseKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+func ParseKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+
+	var (
+		parsed any
+		err    error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newAsymmetricKey(parsed)
+}
+
+func newAsymmetricKey(parsed any) (*Key, error) {
+	key := &Key{}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKeyType, parsed)
+	}
+
+	jwk, err := key.JWK()
+	if err != nil {
+		return nil, err
+	}
+	if key.ID, err = jwk.Thumbprint(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens are
+// still accepted with. Rotation is done by signing with a new key while the
+// previous public key stays in the set until its tokens expire.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+func NewKeySet(signing *Key, verification ...*Key) (*KeySet, error) {
+	if signing == nil || !signing.CanSign() {
+		return nil, ErrNoSigningKey
+	}
+
+	ks := &KeySet{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, key := range verification {
+		if _, ok := ks.keys[key.ID]; ok {
+			if key.ID == signing.ID {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateKeyID, key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	return ks, nil
+}
+
+type KeySetConfig struct {
+	// SigningKeyFile is a PEM private key. When empty, tokens are signed
+	// with HMACSecret.
+	SigningKeyFile string
+	// VerificationKeyFiles are PEM keys accepted in addition to the signing
+	// key, usually public keys of previous signing keys.
+	VerificationKeyFiles []string
+	// HMACSecret is the legacy shared secret. It keeps verifying tokens
+	// issued before asymmetric keys were configured.
+	HMACSecret string
+}
+
+func LoadKeySet(cfg KeySetConfig) (*KeySet, error) {
+	var verification []*Key
+	for _, path := range cfg.VerificationKeyFiles {
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	var hmacKey *Key
+	if cfg.HMACSecret != "" {
+		hmacKey = NewHMACKey(cfg.HMACSecret)
+	}
+
+	if cfg.SigningKeyFile == "" {
+		return NewKeySet(hmacKey, verification...)
+	}
+
+	signing, err := LoadKeyFile(cfg.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if hmacKey != nil {
+		verification = append(verification, hmacKey)
+	}
+
+	return NewKeySet(signing, verification...)
+}
+
+func (ks *KeySet) lookup(kid string) (*Key, bool) {
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// JWK is a public key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *Key) JWK() (JWK, error) {
+	enc := base64.RawURLEncoding
+
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Kid: k.ID,
+			N:   enc.EncodeToString(pub.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Kid: k.ID,
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(pub),
+		}, nil
+	default:
+		return JWK{}, ErrUnsupportedKeyType
+	}
+}
+
+// Thumbprint computes the RFC 7638 SHA-256 thumbprint of the key.
+func (j JWK) Thumbprint() (string, error) {
+	var members any
+	switch j.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	default:
+		return "", ErrUnsupportedKeyType
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// JWKS returns the public keys of the set. The HMAC key is never published.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.sortedKeys() {
+		if jwk, err := key.JWK(); err == nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// sortedKeys lists the signing key first, then the rest by id, so the
+// published set is stable.
+func (ks *KeySet) sortedKeys() []*Key {
+	keys := []*Key{ks.signing}
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		if id != ks.signing.ID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		keys = append(keys, ks.keys[id])
+	}
+	return keys
+}
@@ -0,0 +1,120 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of minor units in one whole unit, amounts keep exactly
+// two fractional digits.
+const Scale = 100
+
+var (
+	ErrInvalidAmount = errors.New("invalid money amount")
+	ErrTooPrecise    = errors.New("money amount has more than two fractional digits")
+	ErrOutOfRange    = errors.New("money amount is out of range")
+)
+
+// numberPattern is the JSON number grammar with the exponent limited to three
+// digits, so parsing never has to materialize huge values.
+var numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]{1,3})?$`)
+
+var (
+	bigScale = big.NewInt(Scale)
+	minInt64 = big.NewInt(math.MinInt64)
+	maxInt64 = big.NewInt(math.MaxInt64)
+)
+
+// Amount is a decimal money value stored as an integer number of minor units
+// (hundredths), so arithmetic and comparisons are exact.
+type Amount int64
+
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// Parse converts a decimal string such as "729.98", "500" or "1.5e2" into an
+// Amount without going through floating point. Values with non-zero digits
+// beyond the second fractional position are rejected.
+func Parse(s string) (Amount, error) {
+	if !numberPattern.MatchString(s) {
+		return 0, ErrInvalidAmount
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, ErrInvalidAmount
+	}
+
+	r.Mul(r, new(big.Rat).SetInt(bigScale))
+	if !r.IsInt() {
+		return 0, ErrTooPrecise
+	}
+
+	minor := r.Num()
+	if minor.Cmp(minInt64) < 0 || minor.Cmp(maxInt64) > 0 {
+		return 0, ErrOutOfRange
+	}
+
+	return Amount(minor.Int64()), nil
+}
+
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// String formats the amount as a plain decimal with trailing fractional
+// zeros dropped: 700, 729.98, 0.5.
+func (a Amount) String() string {
+	minor := int64(a)
+
+	var b strings.Builder
+	abs := uint64(minor)
+	if minor < 0 {
+		b.WriteByte('-')
+		abs = uint64(-minor)
+	}
+
+	b.WriteString(strconv.FormatUint(abs/Scale, 10))
+
+	if frac := abs % Scale; frac != 0 {
+		digits := strconv.FormatUint(frac+Scale, 10)[1:]
+		b.WriteByte('.')
+		b.WriteString(strings.TrimRight(digits, "0"))
+	}
+
+	return b.String()
+}
+
+// MarshalJSON encodes the amount as a JSON number.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts both a JSON number and a JSON string holding one.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return ErrInvalidAmount
+		}
+		s = unquoted
+	}
+
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
@@ -0,0 +1,164 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Amount
+		wantErr error
+	}{
+		{name: "integer", s: "500", want: 50000},
+		{name: "two fractional digits", s: "729.98", want: 72998},
+		{name: "one fractional digit", s: "0.5", want: 50},
+		{name: "zero", s: "0", want: 0},
+		{name: "trailing zeros", s: "1.000", want: 100},
+		{name: "excess fractional digit", s: "1.001", wantErr: ErrTooPrecise},
+		{name: "excess fractional digits", s: "0.123", wantErr: ErrTooPrecise},
+		{name: "exponent", s: "1.5e2", want: 15000},
+		{name: "upper case exponent with sign", s: "1E+2", want: 10000},
+		{name: "negative exponent", s: "1e-2", want: 1},
+		{name: "negative exponent too precise", s: "1e-3", wantErr: ErrTooPrecise},
+		{name: "exponent makes the fraction exact", s: "1.234e1", want: 1234},
+		{name: "exponent out of range", s: "1e999", wantErr: ErrOutOfRange},
+		{name: "exponent too long", s: "1e1000", wantErr: ErrInvalidAmount},
+		{name: "negative", s: "-0.5", want: -50},
+		{name: "negative zero", s: "-0", want: 0},
+		{name: "maximum", s: "92233720368547758.07", want: math.MaxInt64},
+		{name: "above maximum", s: "92233720368547758.08", wantErr: ErrOutOfRange},
+		{name: "minimum", s: "-92233720368547758.08", want: math.MinInt64},
+		{name: "below minimum", s: "-92233720368547758.09", wantErr: ErrOutOfRange},
+		{name: "empty", s: "", wantErr: ErrInvalidAmount},
+		{name: "not a number", s: "abc", wantErr: ErrInvalidAmount},
+		{name: "leading dot", s: ".5", wantErr: ErrInvalidAmount},
+		{name: "trailing dot", s: "5.", wantErr: ErrInvalidAmount},
+		{name: "leading plus", s: "+1", wantErr: ErrInvalidAmount},
+		{name: "leading zero", s: "01", wantErr: ErrInvalidAmount},
+		{name: "comma", s: "1,5", wantErr: ErrInvalidAmount},
+		{name: "surrounding space", s: " 1", wantErr: ErrInvalidAmount},
+		{name: "infinity", s: "Inf", wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.s, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %d, want %d", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{amount: 0, want: "0"},
+		{amount: 70000, want: "700"},
+		{amount: 72998, want: "729.98"},
+		{amount: 50, want: "0.5"},
+		{amount: 5, want: "0.05"},
+		{amount: -105, want: "-1.05"},
+		{amount: math.MaxInt64, want: "92233720368547758.07"},
+		{amount: math.MinInt64, want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.amount.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			parsed, err := Parse(tt.amount.String())
+			if err != nil || parsed != tt.amount {
+				t.Errorf("Parse(String()) = %d, %v, want %d", parsed, err, tt.amount)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Amount
+		wantErr error
+	}{
+		{name: "number", data: `729.98`, want: 72998},
+		{name: "string", data: `"729.98"`, want: 72998},
+		{name: "exponent number", data: `1.5e2`, want: 15000},
+		{name: "exponent string", data: `"1.5e2"`, want: 15000},
+		{name: "negative number", data: `-1.05`, want: -105},
+		{name: "null keeps the value", data: `null`, want: 42},
+		{name: "too precise number", data: `0.001`, wantErr: ErrTooPrecise},
+		{name: "too precise string", data: `"0.001"`, wantErr: ErrTooPrecise},
+		{name: "out of range string", data: `"92233720368547758.08"`, wantErr: ErrOutOfRange},
+		{name: "empty string", data: `""`, wantErr: ErrInvalidAmount},
+		{name: "padded string", data: `" 1"`, wantErr: ErrInvalidAmount},
+		{name: "boolean", data: `true`, wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Amount(42)
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Unmarshal(%s) error = %v, want %v", tt.data, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRoundTrip adds amounts that have no exact binary floating point form,
+// the sum has to come out and go through JSON exactly.
+func TestRoundTrip(t *testing.T) {
+	a, err := Parse("0.1")
+	if err != nil {
+		t.Fatalf("parse 0.1: %v", err)
+	}
+	b, err := Parse("0.2")
+	if err != nil {
+		t.Fatalf("parse 0.2: %v", err)
+	}
+	want, err := Parse("0.3")
+	if err != nil {
+		t.Fatalf("parse 0.3: %v", err)
+	}
+
+	sum := a + b
+	if sum != want {
+		t.Fatalf("0.1 + 0.2 = %s, want %s", sum, want)
+	}
+
+	data, err := json.Marshal(struct {
+		Sum Amount `json:"sum"`
+	}{Sum: sum})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if got := string(data); got != `{"sum":0.3}` {
+		t.Errorf("marshal = %s, want %s", got, `{"sum":0.3}`)
+	}
+
+	var decoded struct {
+		Sum Amount `json:"sum"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.Sum != sum {
+		t.Errorf("round trip = %d, want %d", decoded.Sum, sum)
+	}
+}
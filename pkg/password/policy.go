@@ -0,0 +1,122 @@
+// Package password checks new passwords against a configurable policy.
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ViolationTooShort       = "TOO_SHORT"
+	ViolationTooLong        = "TOO_LONG"
+	ViolationTooFewClasses  = "TOO_FEW_CHARACTER_CLASSES"
+	ViolationCommon         = "COMMON_PASSWORD"
+	ViolationContainsLogin  = "CONTAINS_LOGIN"
+	ViolationSameAsPrevious = "SAME_AS_PREVIOUS"
+)
+
+// Violation is one rule of the policy a password breaks.
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Policy is what a new password must satisfy. MinLength counts characters,
+// MaxLength counts bytes since that is what the hash function is limited by.
+// MinClasses is how many of lower case letters, upper case letters, digits
+// and other characters must occur.
+type Policy struct {
+	MinLength  int
+	MaxLength  int
+	MinClasses int
+}
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = parseCommonPasswords(commonPasswordsFile)
+
+// Check returns every rule password breaks, none when it is acceptable.
+// login is the account the password is for, a password containing it is
+// rejected.
+func (p Policy) Check(password string, login string) []Violation {
+	var violations []Violation
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    ViolationTooShort,
+			Message: fmt.Sprintf("must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, Violation{
+			Code:    ViolationTooLong,
+			Message: fmt.Sprintf("must be at most %d bytes long", p.MaxLength),
+		})
+	}
+
+	if classes(password) < p.MinClasses {
+		violations = append(violations, Violation{
+			Code:    ViolationTooFewClasses,
+			Message: fmt.Sprintf("must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinClasses),
+		})
+	}
+
+	if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+		violations = append(violations, Violation{
+			Code:    ViolationCommon,
+			Message: "is too common",
+		})
+	}
+
+	if login != "" && strings.Contains(strings.ToLower(password), strings.ToLower(login)) {
+		violations = append(violations, Violation{
+			Code:    ViolationContainsLogin,
+			Message: "must not contain the login",
+		})
+	}
+
+	return violations
+}
+
+func classes(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	n := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			n++
+		}
+	}
+	return n
+}
+
+func parseCommonPasswords(data string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords
+}